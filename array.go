@@ -0,0 +1,301 @@
+package sqlhelp
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// In this file: array columns.  Slice fields, other than []byte and types
+// that implement driver.Valuer, are stored as arrays on Postgres, i.e.
+// text[] or bigint[], and as JSON on other databases:
+//
+//	Tags []string `db:"tags"`
+//
+// Only one-dimensional arrays of strings, booleans and numbers are
+// supported.
+
+// isArray returns true if the values of type t are stored as arrays.
+func isArray(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8 && !isLeaf(t)
+}
+
+// encodeValue returns the value for the driver, encoding the slices as
+// arrays for the dialect d.
+func encodeValue(d Dialect, v any) any {
+	if v == nil || !isArray(reflect.TypeOf(v)) {
+		return v
+	}
+	if d == DialectPostgres {
+		return Array(v)
+	}
+	return JSONValue(v)
+}
+
+// Array returns the [driver.Valuer] that encodes the slice v as the Postgres
+// array literal, i.e. {"a","b"}.  A nil slice is stored as NULL.
+func Array(v any) driver.Valuer {
+	return arrayValue{v}
+}
+
+type arrayValue struct {
+	v any
+}
+
+func (a arrayValue) Value() (driver.Value, error) {
+	v := reflect.ValueOf(a.v)
+	if !v.IsValid() || (v.Kind() == reflect.Slice && v.IsNil()) {
+		return nil, nil
+	}
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, fmt.Errorf("cannot encode %T as array", a.v)
+	}
+	var buf strings.Builder
+	buf.WriteByte('{')
+	for i := range v.Len() {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if err := writeElem(&buf, v.Index(i)); err != nil {
+			return nil, err
+		}
+	}
+	buf.WriteByte('}')
+	return buf.String(), nil
+}
+
+// writeElem writes the array element e to buf.
+func writeElem(buf *strings.Builder, e reflect.Value) error {
+	if e.Kind() == reflect.Pointer {
+		if e.IsNil() {
+			buf.WriteString("NULL")
+			return nil
+		}
+		e = e.Elem()
+	}
+	switch e.Kind() {
+	case reflect.String:
+		buf.WriteByte('"')
+		buf.WriteString(strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(e.String()))
+		buf.WriteByte('"')
+	case reflect.Bool:
+		if e.Bool() {
+			buf.WriteByte('t')
+		} else {
+			buf.WriteByte('f')
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		buf.WriteString(strconv.FormatInt(e.Int(), 10))
+	case reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		buf.WriteString(strconv.FormatUint(e.Uint(), 10))
+	case reflect.Float32, reflect.Float64:
+		buf.WriteString(strconv.FormatFloat(e.Float(), 'g', -1, e.Type().Bits()))
+	default:
+		return fmt.Errorf("unsupported array element type %s", e.Type())
+	}
+	return nil
+}
+
+// ScanArray returns the [sql.Scanner] that decodes the Postgres array
+// literal, or the JSON array, into the slice pointed to by dest.  NULL
+// resets dest to nil.
+func ScanArray(dest any) sql.Scanner {
+	return arrayScanner{dest}
+}
+
+type arrayScanner struct {
+	dest any
+}
+
+func (a arrayScanner) Scan(src any) error {
+	v := reflect.ValueOf(a.dest).Elem()
+	var s string
+	switch x := src.(type) {
+	case nil:
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	case []byte:
+		s = string(x)
+	case string:
+		s = x
+	default:
+		return fmt.Errorf("cannot scan %T into array", src)
+	}
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "[") {
+		return json.Unmarshal([]byte(s), a.dest)
+	}
+	elems, err := parseArray(s)
+	if err != nil {
+		return err
+	}
+	res := reflect.MakeSlice(v.Type(), len(elems), len(elems))
+	for i, el := range elems {
+		if err := setElem(res.Index(i), el); err != nil {
+			return fmt.Errorf("array element %d: %w", i, err)
+		}
+	}
+	v.Set(res)
+	return nil
+}
+
+var errArraySyntax = errors.New("invalid array literal")
+
+// parseArray parses the one-dimensional Postgres array literal.  NULL
+// elements are returned as nil.
+func parseArray(s string) ([]*string, error) {
+	if len(s) < 2 || s[0] != '{' || s[len(s)-1] != '}' {
+		return nil, fmt.Errorf("%w: %q", errArraySyntax, s)
+	}
+	s = s[1 : len(s)-1]
+	if s == "" {
+		return []*string{}, nil
+	}
+	var (
+		elems []*string
+		buf   strings.Builder
+	)
+	for i := 0; i <= len(s); i++ {
+		buf.Reset()
+		quoted := i < len(s) && s[i] == '"'
+		if quoted {
+			i++
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' {
+					i++
+				}
+				if i < len(s) {
+					buf.WriteByte(s[i])
+				}
+			}
+			if i >= len(s) {
+				return nil, fmt.Errorf("%w: unterminated quote", errArraySyntax)
+			}
+			i++ // closing quote
+		} else {
+			for ; i < len(s) && s[i] != ','; i++ {
+				if s[i] == '{' || s[i] == '"' {
+					return nil, fmt.Errorf("%w: multi-dimensional arrays are not supported", errArraySyntax)
+				}
+				buf.WriteByte(s[i])
+			}
+		}
+		if i < len(s) && s[i] != ',' {
+			return nil, fmt.Errorf("%w: unexpected %q", errArraySyntax, s[i])
+		}
+		el := buf.String()
+		if !quoted && strings.EqualFold(el, "NULL") {
+			elems = append(elems, nil)
+			continue
+		}
+		elems = append(elems, &el)
+	}
+	return elems, nil
+}
+
+// setElem sets the array element e from its text representation s.
+func setElem(e reflect.Value, s *string) error {
+	if s == nil {
+		e.Set(reflect.Zero(e.Type()))
+		return nil
+	}
+	if e.Kind() == reflect.Pointer {
+		e.Set(reflect.New(e.Type().Elem()))
+		e = e.Elem()
+	}
+	switch e.Kind() {
+	case reflect.String:
+		e.SetString(*s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(*s)
+		if err != nil {
+			return err
+		}
+		e.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(*s, 10, e.Type().Bits())
+		if err != nil {
+			return err
+		}
+		e.SetInt(n)
+	case reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(*s, 10, e.Type().Bits())
+		if err != nil {
+			return err
+		}
+		e.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(*s, e.Type().Bits())
+		if err != nil {
+			return err
+		}
+		e.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported array element type %s", e.Type())
+	}
+	return nil
+}
+
+// EqAny is the Postgres alternative to [sq.Eq] for slices, it renders the
+// "column = ANY(?)" condition with the slice passed as the single array
+// argument, instead of expanding it into the IN list, i.e.:
+//
+//	EqAny{"id": []int64{1, 2, 3}}
+//
+// renders "id = ANY($1)" with the argument "{1,2,3}".  Non-slice values
+// render as "column = ?".  Column names are quoted where necessary.
+type EqAny map[string]any
+
+func (eq EqAny) ToSql() (string, []any, error) {
+	return anyToSql(eq, "= ANY(?)", "= ?")
+}
+
+// NotEqAny is the negated [EqAny], it renders "column <> ALL(?)".
+type NotEqAny map[string]any
+
+func (eq NotEqAny) ToSql() (string, []any, error) {
+	return anyToSql(eq, "<> ALL(?)", "<> ?")
+}
+
+func anyToSql(m map[string]any, arrayOp, op string) (string, []any, error) {
+	cols := make([]string, 0, len(m))
+	for col := range m {
+		cols = append(cols, col)
+	}
+	sort.Strings(cols)
+	var (
+		exprs = make([]string, 0, len(m))
+		args  = make([]any, 0, len(m))
+	)
+	for _, name := range cols {
+		v := m[name]
+		col, err := DialectPostgres.Identifier(name)
+		if err != nil {
+			return "", nil, err
+		}
+		if v != nil && isArray(reflect.TypeOf(v)) {
+			exprs = append(exprs, col+" "+arrayOp)
+			args = append(args, Array(v))
+			continue
+		}
+		exprs = append(exprs, col+" "+op)
+		args = append(args, v)
+	}
+	if len(exprs) == 0 {
+		return "(1=1)", nil, nil
+	}
+	return strings.Join(exprs, " AND "), args, nil
+}
+
+var (
+	_ sq.Sqlizer = EqAny{}
+	_ sq.Sqlizer = NotEqAny{}
+)
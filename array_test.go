@@ -0,0 +1,162 @@
+package sqlhelp
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/rusq/sqlhelp/sqlhelptest"
+	"github.com/stretchr/testify/assert"
+)
+
+type arrayStruct struct {
+	ID     int64     `db:"id,pk,omitempty"`
+	Tags   []string  `db:"tags"`
+	Scores []int64   `db:"scores"`
+	Ratios []float64 `db:"ratios"`
+}
+
+func TestArray_Value(t *testing.T) {
+	s := "x"
+	tests := []struct {
+		name    string
+		v       any
+		want    any
+		wantErr bool
+	}{
+		{"nil slice", []string(nil), nil, false},
+		{"empty", []string{}, "{}", false},
+		{"strings", []string{"a", `b"c`, `d\e`, "f,g"}, `{"a","b\"c","d\\e","f,g"}`, false},
+		{"ints", []int{1, -2, 3}, "{1,-2,3}", false},
+		{"floats", []float64{1.5, 2}, "{1.5,2}", false},
+		{"bools", []bool{true, false}, "{t,f}", false},
+		{"pointers", []*string{&s, nil}, `{"x",NULL}`, false},
+		{"unsupported", []struct{}{{}}, nil, true},
+		{"not a slice", 42, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Array(tt.v).Value()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Value() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestScanArray(t *testing.T) {
+	t.Run("strings", func(t *testing.T) {
+		var got []string
+		assert.NoError(t, ScanArray(&got).Scan([]byte(`{a,"b\"c","d,e",NULL,"NULL"}`)))
+		assert.Equal(t, []string{"a", `b"c`, "d,e", "", "NULL"}, got)
+	})
+	t.Run("ints", func(t *testing.T) {
+		var got []int32
+		assert.NoError(t, ScanArray(&got).Scan("{1,2,3}"))
+		assert.Equal(t, []int32{1, 2, 3}, got)
+	})
+	t.Run("pointers", func(t *testing.T) {
+		var got []*int
+		assert.NoError(t, ScanArray(&got).Scan("{1,NULL}"))
+		if assert.Len(t, got, 2) {
+			assert.Equal(t, 1, *got[0])
+			assert.Nil(t, got[1])
+		}
+	})
+	t.Run("empty", func(t *testing.T) {
+		var got []string
+		assert.NoError(t, ScanArray(&got).Scan("{}"))
+		assert.Equal(t, []string{}, got)
+	})
+	t.Run("json", func(t *testing.T) {
+		var got []bool
+		assert.NoError(t, ScanArray(&got).Scan(`[true,false]`))
+		assert.Equal(t, []bool{true, false}, got)
+	})
+	t.Run("null", func(t *testing.T) {
+		got := []string{"a"}
+		assert.NoError(t, ScanArray(&got).Scan(nil))
+		assert.Nil(t, got)
+	})
+	for _, bad := range []any{"a,b", `{"a}`, "{{1},{2}}", "{x}", 42} {
+		var got []int
+		assert.Error(t, ScanArray(&got).Scan(bad), bad)
+	}
+}
+
+func TestArrayColumns(t *testing.T) {
+	ctx := context.Background()
+	t.Run("postgres", func(t *testing.T) {
+		ddl, err := CreateTableSQL[arrayStruct](DialectPostgres, "arrays")
+		assert.NoError(t, err)
+		assert.Contains(t, ddl, "tags TEXT[]")
+		assert.Contains(t, ddl, "scores BIGINT[]")
+		assert.Contains(t, ddl, "ratios DOUBLE PRECISION[]")
+
+		db, mock := sqlhelptest.InitMockDB(t)
+		mock.ExpectQuery(`INSERT INTO arrays \(ratios,scores,tags\) VALUES \(\$1,\$2,\$3\)`).
+			WithArgs("{0.5}", "{1,2}", `{"a","b"}`).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectQuery(`SELECT id, ratios, scores, tags FROM arrays WHERE id = ANY\(\$1\)`).
+			WithArgs("{1,2}").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "ratios", "scores", "tags"}).
+				AddRow(1, []byte("{0.5}"), []byte("{1,2}"), []byte(`{a,"b"}`)))
+
+		rec := arrayStruct{Tags: []string{"a", "b"}, Scores: []int64{1, 2}, Ratios: []float64{0.5}}
+		_, err = InsertPSQL(ctx, db, "arrays", "id", rec)
+		assert.NoError(t, err)
+		got, err := SelectRow[arrayStruct](ctx, db, "arrays", EqAny{"id": []int{1, 2}})
+		if assert.NoError(t, err) {
+			rec.ID = 1
+			assert.Equal(t, rec, *got)
+		}
+	})
+	t.Run("sqlite", func(t *testing.T) {
+		db := sqlhelptest.InitSqliteDB(t)
+		ddl, err := CreateTableSQL[arrayStruct](DialectSQLite, "arrays")
+		if err != nil {
+			t.Fatal(err)
+		}
+		db.MustExec(ddl)
+		rec := arrayStruct{Tags: []string{"a", "b"}, Scores: []int64{1, 2}}
+		id, err := Insert(ctx, db, "arrays", rec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := SelectRow[arrayStruct](ctx, db, "arrays", sq.Eq{"id": id})
+		if assert.NoError(t, err) {
+			rec.ID = id
+			assert.Equal(t, rec, *got)
+		}
+		assert.NoError(t, VerifySchema[arrayStruct](ctx, db, "arrays"))
+	})
+}
+
+func TestEqAny(t *testing.T) {
+	tests := []struct {
+		name     string
+		cond     sq.Sqlizer
+		wantSQL  string
+		wantArgs []any
+	}{
+		{"slice", EqAny{"id": []int64{1, 2}}, "id = ANY(?)", []any{Array([]int64{1, 2})}},
+		{"scalar", EqAny{"b": 1, "a": []string{"x"}}, "a = ANY(?) AND b = ?", []any{Array([]string{"x"}), 1}},
+		{"not", NotEqAny{"id": []int{1}}, "id <> ALL(?)", []any{Array([]int{1})}},
+		{"empty", EqAny{}, "(1=1)", nil},
+		{"reserved word", EqAny{"user": []int{1}}, `"user" = ANY(?)`, []any{Array([]int{1})}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotSQL, gotArgs, err := tt.cond.ToSql()
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantSQL, gotSQL)
+			assert.Equal(t, tt.wantArgs, gotArgs)
+		})
+	}
+	t.Run("invalid column", func(t *testing.T) {
+		_, _, err := EqAny{"id; DROP TABLE users": 1}.ToSql()
+		assert.ErrorIs(t, err, ErrInvalidIdent)
+	})
+}
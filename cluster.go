@@ -0,0 +1,256 @@
+package sqlhelp
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Cluster is the primary database with the read replicas.  It implements
+// [sqlx.ExtContext], and can be passed to the helpers in place of the
+// database: SELECT queries go to the healthy replicas in round-robin order,
+// and everything else, including SELECT ... FOR UPDATE, SELECT ... INTO, and
+// the SELECTs calling nextval, setval, pg_notify or advisory lock functions,
+// goes to the primary.  If there are no healthy replicas, the primary is
+// used.
+//
+// Reads that must see the preceding writes, and SELECTs calling other
+// functions that write or are not allowed on a standby, i.e. user-defined
+// functions that modify data, must use the context created with
+// [WithPrimary].
+type Cluster struct {
+	primary  *sqlx.DB
+	replicas []*replica
+	next     atomic.Uint64
+
+	maxLag   time.Duration
+	lagQuery string
+}
+
+type replica struct {
+	db      *sqlx.DB
+	healthy atomic.Bool
+}
+
+var _ sqlx.ExtContext = (*Cluster)(nil)
+
+// ClusterOption is the option for [NewCluster].
+type ClusterOption func(*Cluster)
+
+// WithMaxLag sets the maximum replication lag, replicas lagging behind
+// further are considered unhealthy by [Cluster.CheckHealth].  Zero disables
+// the lag check.
+func WithMaxLag(d time.Duration) ClusterOption {
+	return func(c *Cluster) {
+		c.maxLag = d
+	}
+}
+
+// WithLagQuery sets the query that returns the replication lag in seconds.
+// The default query is for Postgres.
+func WithLagQuery(query string) ClusterOption {
+	return func(c *Cluster) {
+		c.lagQuery = query
+	}
+}
+
+// DefaultLagQuery is the Postgres query that returns the replication lag of
+// the replica in seconds.
+const DefaultLagQuery = "SELECT COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)"
+
+// NewCluster returns the cluster of the primary and the replicas.  All
+// replicas are initially considered healthy.
+func NewCluster(primary *sqlx.DB, replicas []*sqlx.DB, opts ...ClusterOption) *Cluster {
+	c := &Cluster{primary: primary, lagQuery: DefaultLagQuery}
+	for _, db := range replicas {
+		r := &replica{db: db}
+		r.healthy.Store(true)
+		c.replicas = append(c.replicas, r)
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Primary returns the primary database.
+func (c *Cluster) Primary() *sqlx.DB {
+	return c.primary
+}
+
+type primaryKey struct{}
+
+// WithPrimary returns the context that routes all queries of the [Cluster]
+// to the primary, for read-your-writes consistency.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// reader returns the database for the read query.
+func (c *Cluster) reader(ctx context.Context, query string) *sqlx.DB {
+	if force, _ := ctx.Value(primaryKey{}).(bool); force || !isReadQuery(query) {
+		return c.primary
+	}
+	n := uint64(len(c.replicas))
+	if n == 0 {
+		return c.primary
+	}
+	start := c.next.Add(1) % n
+	for i := range n {
+		r := c.replicas[(start+i)%n]
+		if r.healthy.Load() {
+			return r.db
+		}
+	}
+	return c.primary
+}
+
+// isReadQuery returns true if the query is the SELECT that does not lock
+// rows, and does not call the functions with side effects (see
+// primaryOnly).
+func isReadQuery(query string) bool {
+	words := strings.FieldsFunc(strings.ToUpper(query), func(r rune) bool {
+		return unicode.IsSpace(r) || r == '(' || r == ')' || r == ';' || r == ','
+	})
+	if len(words) == 0 || words[0] != "SELECT" {
+		return false
+	}
+	for i, w := range words {
+		if primaryOnly(w[strings.LastIndexByte(w, '.')+1:]) {
+			return false
+		}
+		if w != "FOR" {
+			continue
+		}
+		lock := strings.Join(words[i+1:min(i+4, len(words))], " ")
+		for _, clause := range []string{"UPDATE", "SHARE", "NO KEY UPDATE", "KEY SHARE"} {
+			if lock == clause || strings.HasPrefix(lock, clause+" ") {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// primaryOnly returns true if the upper case word makes the SELECT write, or
+// fail on a hot standby: SELECT ... INTO, sequence functions, advisory locks
+// and notifications.  Other such functions must be called with the context
+// created with [WithPrimary].
+func primaryOnly(word string) bool {
+	switch word {
+	case "INTO", "NEXTVAL", "SETVAL", "PG_NOTIFY":
+		return true
+	}
+	return strings.HasPrefix(word, "PG_ADVISORY_") || strings.HasPrefix(word, "PG_TRY_ADVISORY_")
+}
+
+// CheckHealth pings the replicas and checks their replication lag, marking
+// them healthy or unhealthy.  It returns the joined errors of the unhealthy
+// replicas.
+func (c *Cluster) CheckHealth(ctx context.Context) error {
+	var errs []error
+	for _, r := range c.replicas {
+		err := c.check(ctx, r.db)
+		r.healthy.Store(err == nil)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ErrReplicaLag is returned by [Cluster.CheckHealth] when the replica lags
+// behind the primary further than allowed.
+var ErrReplicaLag = errors.New("replica lag exceeds the threshold")
+
+func (c *Cluster) check(ctx context.Context, db *sqlx.DB) error {
+	if err := db.PingContext(ctx); err != nil {
+		return err
+	}
+	if c.maxLag <= 0 {
+		return nil
+	}
+	var secs float64
+	if err := db.QueryRowxContext(ctx, c.lagQuery).Scan(&secs); err != nil {
+		return err
+	}
+	if lag := time.Duration(secs * float64(time.Second)); lag > c.maxLag {
+		return ErrReplicaLag
+	}
+	return nil
+}
+
+// RunHealthChecks runs [Cluster.CheckHealth] every interval until the
+// context is cancelled.
+func (c *Cluster) RunHealthChecks(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		c.CheckHealth(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Healthy returns the number of healthy replicas.
+func (c *Cluster) Healthy() int {
+	n := 0
+	for _, r := range c.replicas {
+		if r.healthy.Load() {
+			n++
+		}
+	}
+	return n
+}
+
+// DriverName returns the driver name of the primary.
+func (c *Cluster) DriverName() string {
+	return c.primary.DriverName()
+}
+
+// Rebind rebinds the query for the primary's bind type.
+func (c *Cluster) Rebind(query string) string {
+	return c.primary.Rebind(query)
+}
+
+// BindNamed binds the named query for the primary's bind type.
+func (c *Cluster) BindNamed(query string, arg any) (string, []any, error) {
+	return c.primary.BindNamed(query, arg)
+}
+
+// QueryContext runs the query on the replica, if it is the read query, or
+// on the primary.
+func (c *Cluster) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return c.reader(ctx, query).QueryContext(ctx, query, args...)
+}
+
+// QueryxContext runs the query on the replica, if it is the read query, or
+// on the primary.
+func (c *Cluster) QueryxContext(ctx context.Context, query string, args ...any) (*sqlx.Rows, error) {
+	return c.reader(ctx, query).QueryxContext(ctx, query, args...)
+}
+
+// QueryRowxContext runs the query on the replica, if it is the read query,
+// or on the primary.
+func (c *Cluster) QueryRowxContext(ctx context.Context, query string, args ...any) *sqlx.Row {
+	return c.reader(ctx, query).QueryRowxContext(ctx, query, args...)
+}
+
+// ExecContext executes the statement on the primary.
+func (c *Cluster) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return c.primary.ExecContext(ctx, query, args...)
+}
+
+// BeginTxx begins the transaction on the primary.
+func (c *Cluster) BeginTxx(ctx context.Context, opts *sql.TxOptions) (*sqlx.Tx, error) {
+	return c.primary.BeginTxx(ctx, opts)
+}
@@ -0,0 +1,123 @@
+package sqlhelp
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+	"github.com/rusq/sqlhelp/sqlhelptest"
+	"github.com/stretchr/testify/assert"
+)
+
+// initPingMockDB is InitMockDB, that expects pings.
+func initPingMockDB(t *testing.T) (*sqlx.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+	return sqlx.NewDb(db, sqlhelptest.Driver), mock
+}
+
+func TestCluster_routing(t *testing.T) {
+	ctx := context.Background()
+	primary, pmock := sqlhelptest.InitMockDB(t)
+	r1, mock1 := sqlhelptest.InitMockDB(t)
+	r2, mock2 := sqlhelptest.InitMockDB(t)
+	c := NewCluster(primary, []*sqlx.DB{r1, r2})
+
+	rows := func() *sqlmock.Rows { return sqlmock.NewRows([]string{"x"}).AddRow(1) }
+	// round-robin reads
+	mock2.ExpectQuery(`SELECT 1 as X FROM users`).WillReturnRows(rows())
+	mock1.ExpectQuery(`SELECT 1 as X FROM users`).WillReturnRows(rows())
+	mock2.ExpectQuery(`SELECT 1 as X FROM users`).WillReturnRows(rows())
+	for range 3 {
+		ok, err := Exists(ctx, c, "users", nil)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	}
+
+	// writes and locking reads go to the primary
+	pmock.ExpectExec(`DELETE FROM users WHERE id = \$1`).WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	pmock.ExpectQuery(`INSERT INTO users`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	pmock.ExpectQuery(`SELECT 1 FROM users FOR UPDATE`).WillReturnRows(rows())
+	assert.NoError(t, DeleteByID(ctx, c, "users", 1))
+	_, err := InsertPSQL(ctx, c, "users", "id", tenantUser{Name: "bob"})
+	assert.NoError(t, err)
+	assert.NoError(t, JustErr(QueryScalar[int](ctx, c, "SELECT 1 FROM users FOR UPDATE")))
+
+	// forced primary read
+	pmock.ExpectQuery(`SELECT id, name FROM users WHERE id = \$1`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "bob"))
+	_, err = SelectRow[tenantUser](WithPrimary(ctx), c, "users", sq.Eq{"id": 1})
+	assert.NoError(t, err)
+}
+
+func TestCluster_CheckHealth(t *testing.T) {
+	ctx := context.Background()
+	primary, pmock := sqlhelptest.InitMockDB(t)
+	r1, mock1 := initPingMockDB(t)
+	r2, mock2 := initPingMockDB(t)
+	c := NewCluster(primary, []*sqlx.DB{r1, r2}, WithMaxLag(5*time.Second))
+	assert.Equal(t, 2, c.Healthy())
+
+	mock1.ExpectPing().WillReturnError(errors.New("down"))
+	mock2.ExpectPing()
+	mock2.ExpectQuery(`SELECT COALESCE`).WillReturnRows(sqlmock.NewRows([]string{"lag"}).AddRow(10.5))
+	err := c.CheckHealth(ctx)
+	assert.ErrorIs(t, err, ErrReplicaLag)
+	assert.Equal(t, 0, c.Healthy())
+
+	// no healthy replicas, reads go to the primary.
+	pmock.ExpectQuery(`SELECT 1`).WillReturnRows(sqlmock.NewRows([]string{"x"}).AddRow(1))
+	assert.NoError(t, JustErr(QueryScalar[int](ctx, c, "SELECT 1")))
+
+	mock1.ExpectPing()
+	mock1.ExpectQuery(`SELECT COALESCE`).WillReturnRows(sqlmock.NewRows([]string{"lag"}).AddRow(0.1))
+	mock2.ExpectPing()
+	mock2.ExpectQuery(`SELECT COALESCE`).WillReturnRows(sqlmock.NewRows([]string{"lag"}).AddRow(1))
+	assert.NoError(t, c.CheckHealth(ctx))
+	assert.Equal(t, 2, c.Healthy())
+}
+
+func Test_isReadQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"SELECT * FROM users", true},
+		{"  select id from users", true},
+		{"SELECT * FROM users FOR UPDATE", false},
+		{"SELECT * FROM users FOR SHARE SKIP LOCKED", false},
+		{"SELECT * FROM users\nFOR UPDATE", false},
+		{"SELECT * FROM users\tFOR\tNO KEY UPDATE;", false},
+		{"SELECT * FROM users FOR KEY SHARE", false},
+		{"SELECT(1) FOR UPDATE", false},
+		{"SELECT * FROM t WHERE for_update = 1", true},
+		{"SELECT 'wait for it' FROM users", true},
+		{"\n\tSELECT id\nFROM users", true},
+		{"SELECT nextval('users_id_seq')", false},
+		{"SELECT pg_catalog.setval('users_id_seq', 1)", false},
+		{"SELECT pg_advisory_lock(1)", false},
+		{"SELECT pg_try_advisory_xact_lock(1)", false},
+		{"SELECT pg_notify('ch', 'hi')", false},
+		{"SELECT * INTO archive FROM users", false},
+		{"SELECT id, intolerance FROM users", true},
+		{"INSERT INTO users VALUES (1)", false},
+		{"WITH d AS (DELETE FROM users RETURNING *) SELECT * FROM d", false},
+		{"UPDATE users SET name = 'SELECT'", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, isReadQuery(tt.query), tt.query)
+	}
+}
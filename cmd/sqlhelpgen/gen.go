@@ -0,0 +1,447 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// generator generates the code for the structs.
+type generator struct {
+	tag string // struct tag name
+}
+
+// pkgInfo holds the parsed package.
+type pkgInfo struct {
+	name    string
+	structs map[string]*structSpec
+	order   []string // struct names in the order of declaration
+	leaves  map[string]bool
+}
+
+// structSpec is the struct type declaration.
+type structSpec struct {
+	st  *ast.StructType
+	doc *ast.CommentGroup
+}
+
+// parseDir parses the non-test Go files in dir, skipping the output file.
+func (g *generator) parseDir(dir string, output string) (*pkgInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	pkg := &pkgInfo{structs: make(map[string]*structSpec), leaves: make(map[string]bool)}
+	fset := token.NewFileSet()
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") ||
+			strings.HasSuffix(name, "_sqlhelp.go") || name == output {
+			continue
+		}
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		if pkg.name == "" {
+			pkg.name = f.Name.Name
+		} else if pkg.name != f.Name.Name {
+			return nil, fmt.Errorf("%s: package %s, expected %s", name, f.Name.Name, pkg.name)
+		}
+		pkg.collect(f)
+	}
+	if pkg.name == "" {
+		return nil, fmt.Errorf("%s: no Go files", dir)
+	}
+	return pkg, nil
+}
+
+// collect collects struct declarations and types that implement
+// driver.Valuer or sql.Scanner from the file.
+func (pkg *pkgInfo) collect(f *ast.File) {
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.GenDecl:
+			if d.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range d.Specs {
+				ts := spec.(*ast.TypeSpec)
+				st, ok := ts.Type.(*ast.StructType)
+				if !ok {
+					continue
+				}
+				doc := ts.Doc
+				if doc == nil && len(d.Specs) == 1 {
+					doc = d.Doc
+				}
+				pkg.structs[ts.Name.Name] = &structSpec{st: st, doc: doc}
+				pkg.order = append(pkg.order, ts.Name.Name)
+			}
+		case *ast.FuncDecl:
+			if d.Recv == nil || len(d.Recv.List) == 0 || (d.Name.Name != "Value" && d.Name.Name != "Scan") {
+				continue
+			}
+			recv := d.Recv.List[0].Type
+			if star, ok := recv.(*ast.StarExpr); ok {
+				recv = star.X
+			}
+			if id, ok := recv.(*ast.Ident); ok {
+				// stored in a single column, not flattened.
+				pkg.leaves[id.Name] = true
+			}
+		}
+	}
+}
+
+// tableDirective is the comment directive that sets the table name.
+const tableDirective = "//sqlhelp:table "
+
+// table returns the table name from the directive in the doc comment.
+func (s *structSpec) table() (string, bool) {
+	if s.doc == nil {
+		return "", false
+	}
+	for _, c := range s.doc.List {
+		if name, ok := strings.CutPrefix(c.Text, tableDirective); ok {
+			return strings.TrimSpace(name), true
+		}
+	}
+	return "", false
+}
+
+// structInfo is the template data for a single struct.
+type structInfo struct {
+	Name   string
+	Table  string
+	Fields []fieldInfo
+}
+
+// fieldInfo is the template data for a single column.
+type fieldInfo struct {
+	Const     string // column constant name
+	Column    string // column name
+	Path      string // selector path from the receiver, i.e. Nested.Street
+	Omitempty bool   // has the omitempty option
+	JSON      bool   // has the json option
+	Array     bool   // slice, stored as the array
+	Encrypted string // "false", "true" if deterministic, or empty if not encrypted
+	Empty     string // expression, that is true if the field is empty
+}
+
+// fileInfo is the template data for the generated file.
+type fileInfo struct {
+	Package string
+	Structs []structInfo
+}
+
+// generate generates the source code for the types.  If types is empty, all
+// structs with the table directive are used.
+func (g *generator) generate(pkg *pkgInfo, types []string) ([]byte, error) {
+	if len(types) == 0 {
+		for _, name := range pkg.order {
+			if _, ok := pkg.structs[name].table(); ok {
+				types = append(types, name)
+			}
+		}
+		if len(types) == 0 {
+			return nil, fmt.Errorf("no types with %q directive", strings.TrimSpace(tableDirective))
+		}
+	}
+	data := fileInfo{Package: pkg.name}
+	for _, name := range types {
+		spec, ok := pkg.structs[name]
+		if !ok {
+			return nil, fmt.Errorf("struct type %s not found", name)
+		}
+		table, ok := spec.table()
+		if !ok {
+			table = snakeCase(name) + "s"
+		}
+		si := structInfo{Name: name, Table: table}
+		fields, err := g.fields(pkg, spec.st, "")
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		seen := make(map[string]bool)
+		consts := make(map[string]bool)
+		for _, f := range fields {
+			if seen[f.Column] {
+				continue
+			}
+			seen[f.Column] = true
+			f.Const = name + "Col" + f.Path[strings.LastIndex(f.Path, ".")+1:]
+			if consts[f.Const] {
+				f.Const = name + "Col" + strings.ReplaceAll(f.Path, ".", "")
+			}
+			consts[f.Const] = true
+			si.Fields = append(si.Fields, f)
+		}
+		data.Structs = append(data.Structs, si)
+	}
+
+	var buf bytes.Buffer
+	if err := fileTmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
+
+// fields returns the columns of the struct st, flattening nested structs
+// declared in the package.
+func (g *generator) fields(pkg *pkgInfo, st *ast.StructType, prefix string) ([]fieldInfo, error) {
+	var ff []fieldInfo
+	for _, fld := range st.Fields.List {
+		column, opts := g.parseTag(fld.Tag)
+		if column == "-" {
+			continue
+		}
+		names := make([]string, 0, len(fld.Names))
+		for _, id := range fld.Names {
+			names = append(names, id.Name)
+		}
+		embedded := len(names) == 0
+		if embedded {
+			names = append(names, typeName(fld.Type))
+		}
+		for _, name := range names {
+			if !ast.IsExported(name) {
+				continue
+			}
+			path := prefix + name
+			isJSON := slices.Contains(opts, "json")
+			if nested, ok := pkg.nested(fld.Type); ok && !isJSON {
+				nf, err := g.fields(pkg, nested, path+".")
+				if err != nil {
+					return nil, err
+				}
+				ff = append(ff, nf...)
+				continue
+			}
+			if embedded && typeName(fld.Type) != "Time" {
+				return nil, fmt.Errorf("cannot resolve embedded type %s", exprString(fld.Type))
+			}
+			col := column
+			if col == "" {
+				col = name
+			}
+			empty := pkg.emptyExpr(fld.Type, "x."+path)
+			ff = append(ff, fieldInfo{
+				Column:    col,
+				Path:      path,
+				Omitempty: slices.Contains(opts, "omitempty") && empty != "false",
+				JSON:      isJSON,
+				Array:     !isJSON && isSlice(fld.Type),
+				Encrypted: encryptedOpt(opts),
+				Empty:     empty,
+			})
+		}
+	}
+	return ff, nil
+}
+
+// nested returns the struct type, if the field of type expr is a struct that
+// is flattened.
+func (pkg *pkgInfo) nested(expr ast.Expr) (*ast.StructType, bool) {
+	switch t := expr.(type) {
+	case *ast.StructType:
+		return t, true
+	case *ast.Ident:
+		if spec, ok := pkg.structs[t.Name]; ok && !pkg.leaves[t.Name] {
+			return spec.st, true
+		}
+	}
+	return nil, false
+}
+
+// parseTag returns the column name and options from the field tag.
+func (g *generator) parseTag(lit *ast.BasicLit) (string, []string) {
+	if lit == nil {
+		return "", nil
+	}
+	s, err := strconv.Unquote(lit.Value)
+	if err != nil {
+		return "", nil
+	}
+	parts := strings.Split(reflect.StructTag(s).Get(g.tag), ",")
+	return parts[0], parts[1:]
+}
+
+// encryptedOpt returns the deterministic argument for sqlhelp.Encrypted, if
+// the field has the "encrypted" option, or an empty string.
+func encryptedOpt(opts []string) string {
+	for _, opt := range opts {
+		switch opt {
+		case "encrypted":
+			return "false"
+		case "encrypted=deterministic":
+			return "true"
+		}
+	}
+	return ""
+}
+
+// isSlice returns true if expr is the slice type, other than []byte.
+func isSlice(expr ast.Expr) bool {
+	t, ok := expr.(*ast.ArrayType)
+	if !ok || t.Len != nil {
+		return false
+	}
+	elt := exprString(t.Elt)
+	return elt != "byte" && elt != "uint8"
+}
+
+// typeName returns the name of the type, without the package and pointer.
+func typeName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return t.Name
+	case *ast.StarExpr:
+		return typeName(t.X)
+	case *ast.SelectorExpr:
+		return t.Sel.Name
+	}
+	return ""
+}
+
+// emptyExpr returns the expression that is true if the value v of the type
+// expr is empty, matching the "omitempty" semantics of sqlhelp: structs,
+// other than time.Time, are never empty.
+func (pkg *pkgInfo) emptyExpr(expr ast.Expr, v string) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		switch t.Name {
+		case "string":
+			return v + ` == ""`
+		case "bool":
+			return "!" + v
+		case "int", "int8", "int16", "int32", "int64",
+			"uint", "uint8", "uint16", "uint32", "uint64", "uintptr",
+			"float32", "float64", "byte", "rune":
+			return v + " == 0"
+		}
+		if _, ok := pkg.structs[t.Name]; ok {
+			return "false"
+		}
+	case *ast.StructType:
+		return "false"
+	case *ast.ArrayType, *ast.MapType:
+		return "len(" + v + ") == 0"
+	case *ast.StarExpr, *ast.InterfaceType, *ast.FuncType, *ast.ChanType:
+		return v + " == nil"
+	case *ast.SelectorExpr:
+		if exprString(t) == "time.Time" {
+			return v + ".IsZero()"
+		}
+	}
+	return "sqlhelp.IsEmpty(" + v + ")"
+}
+
+func exprString(expr ast.Expr) string {
+	var buf bytes.Buffer
+	format.Node(&buf, token.NewFileSet(), expr)
+	return buf.String()
+}
+
+// snakeCase converts CamelCase to snake_case.
+func snakeCase(s string) string {
+	var buf strings.Builder
+	runes := []rune(s)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+				buf.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		buf.WriteRune(r)
+	}
+	return buf.String()
+}
+
+var fileTmpl = template.Must(template.New("file").Parse(`// Code generated by sqlhelpgen; DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"fmt"
+
+	"github.com/rusq/sqlhelp"
+)
+{{range .Structs}}
+// {{.Name}}Table is the name of the table that holds {{.Name}}.
+const {{.Name}}Table = "{{.Table}}"
+
+// Columns of {{.Name}}.
+const (
+{{- range .Fields}}
+	{{.Const}} = "{{.Column}}"
+{{- end}}
+)
+
+var (
+	_ sqlhelp.ColumnMapper = {{.Name}}{}
+	_ sqlhelp.RowScanner   = (*{{.Name}})(nil)
+)
+
+// TableName returns the name of the table that holds {{.Name}}.
+func ({{.Name}}) TableName() string {
+	return {{.Name}}Table
+}
+
+// ToMap implements sqlhelp.ColumnMapper.
+func (x {{.Name}}) ToMap(omitEmpty bool) map[string]any {
+	m := make(map[string]any, {{len .Fields}})
+{{- range .Fields}}
+{{- if .Omitempty}}
+	if !omitEmpty || !({{.Empty}}) {
+		m[{{.Const}}] = {{template "value" .}}
+	}
+{{- else}}
+	m[{{.Const}}] = {{template "value" .}}
+{{- end}}
+{{- end}}
+	return m
+}
+
+// ScanRow implements sqlhelp.RowScanner.
+func (x *{{.Name}}) ScanRow(columns []string, scan func(dest ...any) error) error {
+	dest := make([]any, len(columns))
+	for i, col := range columns {
+		switch col {
+{{- range .Fields}}
+		case {{.Const}}:
+{{- if .JSON}}
+			dest[i] = sqlhelp.ScanJSON(&x.{{.Path}})
+{{- else if .Encrypted}}
+			dest[i] = sqlhelp.ScanEncrypted(&x.{{.Path}})
+{{- else if .Array}}
+			dest[i] = sqlhelp.ScanArray(&x.{{.Path}})
+{{- else}}
+			dest[i] = &x.{{.Path}}
+{{- end}}
+{{- end}}
+		default:
+			return fmt.Errorf("%w: {{.Name}}.%s", sqlhelp.ErrUnknownColumn, col)
+		}
+	}
+	return scan(dest...)
+}
+{{end}}
+{{- define "value"}}
+{{- if .JSON}}sqlhelp.JSONValue(x.{{.Path}})
+{{- else if .Encrypted}}sqlhelp.Encrypted(x.{{.Path}}, {{.Encrypted}})
+{{- else}}x.{{.Path}}
+{{- end}}
+{{- end}}`))
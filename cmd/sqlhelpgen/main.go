@@ -0,0 +1,88 @@
+// Command sqlhelpgen generates column name constants, table name methods and
+// non-reflective mapping functions for structs with db tags, for use with the
+// sqlhelp package.
+//
+// Usage:
+//
+//	//go:generate go run github.com/rusq/sqlhelp/cmd/sqlhelpgen -type User,Post
+//
+// For each type, sqlhelpgen generates:
+//
+//   - column name constants, i.e. UserColID = "id";
+//   - TableName method and the table name constant, i.e. UserTable = "users";
+//   - ToMap method, implementing sqlhelp.ColumnMapper;
+//   - ScanRow method, implementing sqlhelp.RowScanner.
+//
+// The table name is taken from the "sqlhelp:table" directive in the type
+// comment, and defaults to the snake_case name of the type with the "s"
+// suffix:
+//
+//	//sqlhelp:table people
+//	type Person struct {
+//		ID   int64  `db:"id,pk,omitempty"`
+//		Name string `db:"name"`
+//	}
+//
+// Fields with the "json" tag option are stored as JSON, using
+// sqlhelp.JSONValue and sqlhelp.ScanJSON, fields with the "encrypted" option
+// use sqlhelp.Encrypted and sqlhelp.ScanEncrypted, and slices are scanned
+// with sqlhelp.ScanArray.
+//
+// The generated code behaves as the reflective mapping of sqlhelp: ToMap
+// omits the same empty fields, and ScanRow returns sqlhelp.ErrUnknownColumn
+// for the columns that are not mapped.
+//
+// If the -type flag is not given, all structs with the "sqlhelp:table"
+// directive are processed.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	typeNames = flag.String("type", "", "comma-separated list of type names; if empty, types with sqlhelp:table directive are used")
+	output    = flag.String("output", "", "output file name; default <package>_sqlhelp.go")
+	tag       = flag.String("tag", "db", "struct tag name")
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("sqlhelpgen: ")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: sqlhelpgen [flags] [directory]\n\nFlags:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+	var types []string
+	if *typeNames != "" {
+		types = strings.Split(*typeNames, ",")
+	}
+
+	g := generator{tag: *tag}
+	pkg, err := g.parseDir(dir, *output)
+	if err != nil {
+		log.Fatal(err)
+	}
+	src, err := g.generate(pkg, types)
+	if err != nil {
+		log.Fatal(err)
+	}
+	outName := *output
+	if outName == "" {
+		outName = pkg.name + "_sqlhelp.go"
+	}
+	if err := os.WriteFile(filepath.Join(dir, outName), src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
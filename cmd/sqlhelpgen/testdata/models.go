@@ -0,0 +1,38 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+//sqlhelp:table people
+type Person struct {
+	ID        int64          `db:"id,pk,omitempty"`
+	Name      string         `db:"name"`
+	Email     sql.NullString `db:"email"`
+	Tags      []string       `db:"tags,omitempty"`
+	CreatedAt time.Time      `db:"created_at,omitempty"`
+	Status    Status         `db:"status,omitempty"`
+	Note      sql.NullString `db:"note,omitempty"`
+	Home      *Address       `db:"home,json,omitempty"`
+	Work      Address        `db:"work,json,omitempty"`
+	Meta      map[string]any `db:"meta,json,omitempty"`
+	Billing   Address        `db:"billing,json"`
+	SSN       string         `db:"ssn,encrypted"`
+	Phone     *string        `db:"phone,encrypted=deterministic"`
+	Address
+	Ignored string `db:"-"`
+	secret  string
+}
+
+type Address struct {
+	Street string `db:"street,omitempty"`
+	City   string `db:"city"`
+}
+
+type Status string
+
+type APIKey struct {
+	Key   string `db:"key"`
+	Owner int64  `db:"owner_id"`
+}
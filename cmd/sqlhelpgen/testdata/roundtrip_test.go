@@ -0,0 +1,95 @@
+package models
+
+// This test is run by TestGenerate_roundTrip against the generated code, it
+// checks that the generated mapping matches the reflective mapping of
+// sqlhelp.
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/rusq/sqlhelp"
+	"github.com/stretchr/testify/assert"
+)
+
+// plainPerson has no methods, so it is mapped by reflection.
+type plainPerson Person
+
+// recorder is the sqlx.ExtContext that records the executed statement.
+type recorder struct {
+	query string
+	args  []any
+}
+
+func (r *recorder) DriverName() string         { return "postgres" }
+func (r *recorder) Rebind(query string) string { return sqlx.Rebind(sqlx.DOLLAR, query) }
+func (r *recorder) BindNamed(query string, arg any) (string, []any, error) {
+	return sqlx.BindNamed(sqlx.DOLLAR, query, arg)
+}
+
+func (r *recorder) QueryContext(context.Context, string, ...any) (*sql.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+func (r *recorder) QueryxContext(context.Context, string, ...any) (*sqlx.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+func (r *recorder) QueryRowxContext(context.Context, string, ...any) *sqlx.Row {
+	return nil
+}
+
+func (r *recorder) ExecContext(_ context.Context, query string, args ...any) (sql.Result, error) {
+	r.query, r.args = query, args
+	return sqlmock.NewResult(1, 1), nil
+}
+
+func TestToMap_matchesReflection(t *testing.T) {
+	ctx := context.Background()
+	phone := "555"
+	tests := []struct {
+		name string
+		p    Person
+	}{
+		{"zero", Person{}},
+		{
+			"filled",
+			Person{
+				ID:        1,
+				Name:      "bob",
+				Email:     sql.NullString{String: "bob@example.com", Valid: true},
+				Tags:      []string{"a", "b"},
+				CreatedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+				Status:    "active",
+				Note:      sql.NullString{String: "note", Valid: true},
+				Home:      &Address{Street: "home st"},
+				Meta:      map[string]any{"k": "v"},
+				SSN:       "123",
+				Phone:     &phone,
+				Address:   Address{Street: "main st", City: "x"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		for _, omitEmpty := range []bool{true, false} {
+			var gen, refl recorder
+			_, err := sqlhelp.InsertFull(ctx, &gen, omitEmpty, PersonTable, tt.p)
+			assert.NoError(t, err)
+			_, err = sqlhelp.InsertFull(ctx, &refl, omitEmpty, PersonTable, plainPerson(tt.p))
+			assert.NoError(t, err)
+			assert.Equal(t, refl.query, gen.query, "%s, omitEmpty=%v", tt.name, omitEmpty)
+			assert.Equal(t, refl.args, gen.args, "%s, omitEmpty=%v", tt.name, omitEmpty)
+		}
+	}
+}
+
+func TestScanRow_unknownColumn(t *testing.T) {
+	var p Person
+	err := p.ScanRow([]string{PersonColID, "nope"}, func(dest ...any) error { return nil })
+	assert.ErrorIs(t, err, sqlhelp.ErrUnknownColumn)
+}
@@ -0,0 +1,65 @@
+package sqlhelp
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Config is the database connection configuration, see [PGConfig],
+// [SQLiteConfig] and [MySQLConfig].
+type Config interface {
+	// DriverName returns the name of the database/sql driver.
+	DriverName() string
+	// DSN returns the connection string for the driver.
+	DSN() string
+	// PoolSettings returns the connection pool settings.
+	PoolSettings() Pool
+}
+
+// Pool is the connection pool settings, embedded in the configs.  Zero
+// values leave the database/sql defaults.
+type Pool struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// PoolSettings returns the pool settings.
+func (p Pool) PoolSettings() Pool {
+	return p
+}
+
+// apply applies the pool settings to the db.
+func (p Pool) apply(db *sqlx.DB) {
+	if p.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(p.MaxOpenConns)
+	}
+	if p.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(p.MaxIdleConns)
+	}
+	if p.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(p.ConnMaxLifetime)
+	}
+	if p.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(p.ConnMaxIdleTime)
+	}
+}
+
+// Open opens the database with the config, applies the pool settings and
+// verifies the connection.  The driver must be registered by the caller,
+// i.e. with the blank import.
+func Open(ctx context.Context, cfg Config) (*sqlx.DB, error) {
+	db, err := sqlx.Open(cfg.DriverName(), cfg.DSN())
+	if err != nil {
+		return nil, err
+	}
+	cfg.PoolSettings().apply(db)
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
@@ -0,0 +1,105 @@
+package sqlhelp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSQLiteConfig_DSN(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  *SQLiteConfig
+		want string
+	}{
+		{"plain", NewSQLiteConfig("app.db"), "app.db"},
+		{
+			"pragmas",
+			NewSQLiteConfig("app.db").SetForeignKeys(true).SetJournalMode("WAL").SetBusyTimeout(5 * time.Second),
+			"app.db?_pragma=busy_timeout%285000%29&_pragma=foreign_keys%281%29&_pragma=journal_mode%28WAL%29",
+		},
+		{
+			"params",
+			&SQLiteConfig{Path: "file:app.db", Params: map[string]string{"_txlock": "immediate"}},
+			"file:app.db?_txlock=immediate",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.cfg.DSN())
+			assert.Equal(t, "sqlite", tt.cfg.DriverName())
+		})
+	}
+}
+
+func TestMySQLConfig_DSN(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  func() *MySQLConfig
+		want string
+	}{
+		{
+			"plain",
+			func() *MySQLConfig { return NewMySQLConfig("localhost:3306", "app") },
+			"tcp(localhost:3306)/app",
+		},
+		{
+			"full",
+			func() *MySQLConfig {
+				cfg := NewMySQLConfig("db:3306", "app")
+				cfg.User, cfg.Password = "user", "pass"
+				cfg.ParseTime = true
+				cfg.Loc = time.UTC
+				cfg.MultiStatements = true
+				cfg.Params["charset"] = "utf8mb4"
+				return cfg
+			},
+			"user:pass@tcp(db:3306)/app?charset=utf8mb4&loc=UTC&multiStatements=true&parseTime=true",
+		},
+		{
+			"socket",
+			func() *MySQLConfig {
+				return &MySQLConfig{User: "root", Net: "unix", Addr: "/run/mysqld.sock", Database: "app", Loc: time.Local}
+			},
+			"root@unix(/run/mysqld.sock)/app?loc=Local",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg()
+			assert.Equal(t, tt.want, cfg.DSN())
+			assert.Equal(t, "mysql", cfg.DriverName())
+		})
+	}
+}
+
+func TestOpen(t *testing.T) {
+	ctx := context.Background()
+	t.Run("sqlite", func(t *testing.T) {
+		cfg := NewSQLiteConfig(":memory:").SetForeignKeys(true).SetBusyTimeout(time.Second)
+		cfg.MaxOpenConns = 1
+		cfg.ConnMaxIdleTime = time.Minute
+		db, err := Open(ctx, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		assert.Equal(t, 1, db.Stats().MaxOpenConnections)
+		assert.Equal(t, DialectSQLite, DialectOf(db))
+		var fk, timeout int
+		assert.NoError(t, db.GetContext(ctx, &fk, "PRAGMA foreign_keys"))
+		assert.NoError(t, db.GetContext(ctx, &timeout, "PRAGMA busy_timeout"))
+		assert.Equal(t, 1, fk)
+		assert.Equal(t, 1000, timeout)
+	})
+	t.Run("unknown driver", func(t *testing.T) {
+		_, err := Open(ctx, &SQLiteConfig{Path: ":memory:", Driver: "nope"})
+		assert.Error(t, err)
+	})
+	t.Run("ping fails", func(t *testing.T) {
+		_, err := Open(ctx, NewSQLiteConfig("/nonexistent/dir/app.db"))
+		assert.Error(t, err)
+	})
+}
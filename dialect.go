@@ -0,0 +1,55 @@
+package sqlhelp
+
+import "strings"
+
+// Dialect is the SQL dialect spoken by the database.
+type Dialect int
+
+const (
+	DialectUnknown Dialect = iota
+	DialectPostgres
+	DialectSQLite
+	DialectMySQL
+)
+
+func (d Dialect) String() string {
+	switch d {
+	case DialectPostgres:
+		return "postgres"
+	case DialectSQLite:
+		return "sqlite"
+	case DialectMySQL:
+		return "mysql"
+	default:
+		return "unknown"
+	}
+}
+
+// QuoteIdent quotes the identifier, i.e. "users" on Postgres and SQLite,
+// or `users` (in backticks) on MySQL.
+func (d Dialect) QuoteIdent(name string) string {
+	if d == DialectMySQL {
+		return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// DialectOf returns the dialect of the database, based on its driver name.
+// [sqlx.DB], [sqlx.Tx] and any [sqlx.ExtContext] satisfy the interface.
+func DialectOf(db interface{ DriverName() string }) Dialect {
+	return dialectFor(db.DriverName())
+}
+
+// dialectFor returns the dialect for the driver name.
+func dialectFor(driverName string) Dialect {
+	switch driverName {
+	case "postgres", "pgx", "pgx/v4", "pgx/v5", "cloudsqlpostgres", "nrpostgres":
+		return DialectPostgres
+	case "sqlite", "sqlite3":
+		return DialectSQLite
+	case "mysql", "nrmysql":
+		return DialectMySQL
+	default:
+		return DialectUnknown
+	}
+}
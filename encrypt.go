@@ -0,0 +1,236 @@
+package sqlhelp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// In this file: encrypted columns.  Fields with the "encrypted" tag option
+// are encrypted with the [ColumnCipher] on insert and update, and decrypted
+// on select, i.e.:
+//
+//	SSN   string `db:"ssn,encrypted"`
+//	Email string `db:"email,encrypted=deterministic"`
+//
+// The ciphertext is stored as text.  In the deterministic mode, the same
+// plaintext always produces the same ciphertext, so the column can be
+// looked up with [EqEncrypted], at the cost of revealing equal values.
+// Only string and []byte fields, and pointers to them, can be encrypted.
+
+// Cipher encrypts and decrypts the column values.
+type Cipher interface {
+	// Encrypt encrypts the plaintext.  If deterministic is true, the same
+	// plaintext must produce the same ciphertext.
+	Encrypt(plaintext []byte, deterministic bool) ([]byte, error)
+	// Decrypt decrypts the ciphertext produced by Encrypt.
+	Decrypt(ciphertext []byte) ([]byte, error)
+}
+
+// ColumnCipher is the cipher used for the encrypted columns.  It must be set
+// before the structs with encrypted fields are inserted or selected.
+var ColumnCipher Cipher
+
+// ErrNoCipher is returned when the [ColumnCipher] is not set.
+var ErrNoCipher = errors.New("column cipher is not set")
+
+// encryptOpt returns whether the field is encrypted, and whether it uses
+// the deterministic mode.
+func encryptOpt(opts tagOptions) (encrypted, deterministic bool) {
+	if opts.has("encrypted") {
+		return true, false
+	}
+	mode, ok := opts.value("encrypted")
+	return ok, mode == "deterministic"
+}
+
+// Encrypted returns the [driver.Valuer] that encrypts v, a string or
+// []byte, with the [ColumnCipher].  A nil v is stored as NULL.
+func Encrypted(v any, deterministic bool) driver.Valuer {
+	return encryptedValue{v, deterministic}
+}
+
+type encryptedValue struct {
+	v             any
+	deterministic bool
+}
+
+func (e encryptedValue) Value() (driver.Value, error) {
+	v := reflect.ValueOf(e.v)
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+	var plain []byte
+	switch {
+	case !v.IsValid():
+		return nil, nil
+	case v.Kind() == reflect.String:
+		plain = []byte(v.String())
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+		if v.IsNil() {
+			return nil, nil
+		}
+		plain = v.Bytes()
+	default:
+		return nil, fmt.Errorf("cannot encrypt %s", v.Type())
+	}
+	if ColumnCipher == nil {
+		return nil, ErrNoCipher
+	}
+	ct, err := ColumnCipher.Encrypt(plain, e.deterministic)
+	if err != nil {
+		return nil, err
+	}
+	return string(ct), nil
+}
+
+// ScanEncrypted returns the [sql.Scanner] that decrypts the column value
+// with the [ColumnCipher] into dest, a pointer to string or []byte.  NULL
+// resets dest to the zero value.
+func ScanEncrypted(dest any) sql.Scanner {
+	return encryptedScanner{dest}
+}
+
+type encryptedScanner struct {
+	dest any
+}
+
+func (e encryptedScanner) Scan(src any) error {
+	v := reflect.ValueOf(e.dest).Elem()
+	var ct []byte
+	switch s := src.(type) {
+	case nil:
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	case []byte:
+		ct = s
+	case string:
+		ct = []byte(s)
+	default:
+		return fmt.Errorf("cannot decrypt %T", src)
+	}
+	if ColumnCipher == nil {
+		return ErrNoCipher
+	}
+	plain, err := ColumnCipher.Decrypt(ct)
+	if err != nil {
+		return err
+	}
+	if v.Kind() == reflect.Pointer {
+		v.Set(reflect.New(v.Type().Elem()))
+		v = v.Elem()
+	}
+	switch {
+	case v.Kind() == reflect.String:
+		v.SetString(string(plain))
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+		v.SetBytes(plain)
+	default:
+		return fmt.Errorf("cannot decrypt into %s", v.Type())
+	}
+	return nil
+}
+
+// EqEncrypted returns the "column = ?" condition with v encrypted in the
+// deterministic mode.  It only matches the values encrypted with the
+// current key of the deterministic column.
+func EqEncrypted(column string, v any) sq.Sqlizer {
+	return sq.Eq{column: Encrypted(v, true)}
+}
+
+// AESCipher is the AES-GCM [Cipher] with the key rotation support.  Values
+// are encrypted with the current key, and the key ID is stored as the prefix
+// of the ciphertext, so that values encrypted with the previous keys can
+// still be decrypted.  The ciphertext format is "<key ID>$<base64 data>".
+//
+// In the deterministic mode, the nonce is derived from the plaintext with
+// HMAC-SHA256.
+type AESCipher struct {
+	current string
+	keys    map[string]aesKey
+}
+
+type aesKey struct {
+	aead cipher.AEAD
+	mac  []byte // HMAC key for the deterministic nonce
+}
+
+// NewAESCipher returns the AES-GCM cipher with the keys, keyed by key ID.
+// The keys must be 16, 24 or 32 bytes long.  New values are encrypted with
+// the current key.
+func NewAESCipher(current string, keys map[string][]byte) (*AESCipher, error) {
+	if _, ok := keys[current]; !ok {
+		return nil, fmt.Errorf("current key %q not found", current)
+	}
+	c := &AESCipher{current: current, keys: make(map[string]aesKey, len(keys))}
+	for id, key := range keys {
+		if id == "" || strings.Contains(id, "$") {
+			return nil, fmt.Errorf("invalid key ID %q", id)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		h := hmac.New(sha256.New, key)
+		h.Write([]byte("sqlhelp deterministic nonce"))
+		c.keys[id] = aesKey{aead: aead, mac: h.Sum(nil)}
+	}
+	return c, nil
+}
+
+// Encrypt encrypts the plaintext with the current key.
+func (c *AESCipher) Encrypt(plaintext []byte, deterministic bool) ([]byte, error) {
+	k := c.keys[c.current]
+	nonce := make([]byte, k.aead.NonceSize())
+	if deterministic {
+		h := hmac.New(sha256.New, k.mac)
+		h.Write(plaintext)
+		copy(nonce, h.Sum(nil))
+	} else if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	data := k.aead.Seal(nonce, nonce, plaintext, []byte(c.current))
+	out := make([]byte, 0, len(c.current)+1+base64.RawStdEncoding.EncodedLen(len(data)))
+	out = append(out, c.current...)
+	out = append(out, '$')
+	return base64.RawStdEncoding.AppendEncode(out, data), nil
+}
+
+// Decrypt decrypts the ciphertext with the key identified by its prefix.
+func (c *AESCipher) Decrypt(ciphertext []byte) ([]byte, error) {
+	id, enc, found := strings.Cut(string(ciphertext), "$")
+	if !found {
+		return nil, errors.New("invalid ciphertext: no key ID")
+	}
+	k, ok := c.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown key ID %q", id)
+	}
+	data, err := base64.RawStdEncoding.DecodeString(enc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ciphertext: %w", err)
+	}
+	ns := k.aead.NonceSize()
+	if len(data) < ns {
+		return nil, errors.New("invalid ciphertext: too short")
+	}
+	return k.aead.Open(nil, data[:ns], data[ns:], []byte(id))
+}
@@ -0,0 +1,156 @@
+package sqlhelp
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/rusq/sqlhelp/sqlhelptest"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testKey1 = bytes.Repeat([]byte{1}, 32)
+	testKey2 = bytes.Repeat([]byte{2}, 16)
+)
+
+type encryptedStruct struct {
+	ID    int64   `db:"id,pk,omitempty"`
+	SSN   string  `db:"ssn,encrypted"`
+	Email string  `db:"email,encrypted=deterministic"`
+	Note  *string `db:"note,encrypted"`
+	Blob  []byte  `db:"blob,encrypted"`
+}
+
+func setTestCipher(t *testing.T, current string) {
+	t.Helper()
+	c, err := NewAESCipher(current, map[string][]byte{"k1": testKey1, "k2": testKey2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := ColumnCipher
+	t.Cleanup(func() { ColumnCipher = old })
+	ColumnCipher = c
+}
+
+func TestAESCipher(t *testing.T) {
+	c, err := NewAESCipher("k1", map[string][]byte{"k1": testKey1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := []byte("123-45-6789")
+
+	t.Run("randomised", func(t *testing.T) {
+		ct1, err := c.Encrypt(plain, false)
+		assert.NoError(t, err)
+		ct2, err := c.Encrypt(plain, false)
+		assert.NoError(t, err)
+		assert.NotEqual(t, ct1, ct2)
+		assert.True(t, bytes.HasPrefix(ct1, []byte("k1$")))
+		got, err := c.Decrypt(ct1)
+		assert.NoError(t, err)
+		assert.Equal(t, plain, got)
+	})
+	t.Run("deterministic", func(t *testing.T) {
+		ct1, err := c.Encrypt(plain, true)
+		assert.NoError(t, err)
+		ct2, err := c.Encrypt(plain, true)
+		assert.NoError(t, err)
+		assert.Equal(t, ct1, ct2)
+		other, err := c.Encrypt([]byte("other"), true)
+		assert.NoError(t, err)
+		assert.NotEqual(t, ct1, other)
+	})
+	t.Run("rotation", func(t *testing.T) {
+		ct, err := c.Encrypt(plain, false)
+		assert.NoError(t, err)
+		rotated, err := NewAESCipher("k2", map[string][]byte{"k1": testKey1, "k2": testKey2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := rotated.Decrypt(ct)
+		assert.NoError(t, err)
+		assert.Equal(t, plain, got)
+		ct2, err := rotated.Encrypt(plain, false)
+		assert.NoError(t, err)
+		assert.True(t, bytes.HasPrefix(ct2, []byte("k2$")))
+	})
+	t.Run("invalid", func(t *testing.T) {
+		for _, ct := range []string{"nokey", "k9$AAAA", "k1$!!!", "k1$AAAA"} {
+			_, err := c.Decrypt([]byte(ct))
+			assert.Error(t, err, ct)
+		}
+		ct, _ := c.Encrypt(plain, false)
+		ct[len(ct)-2] ^= 1
+		_, err := c.Decrypt(ct)
+		assert.Error(t, err, "tampered")
+	})
+	t.Run("bad keys", func(t *testing.T) {
+		_, err := NewAESCipher("k1", map[string][]byte{"k2": testKey2})
+		assert.Error(t, err)
+		_, err = NewAESCipher("k1", map[string][]byte{"k1": []byte("short")})
+		assert.Error(t, err)
+		_, err = NewAESCipher("k$1", map[string][]byte{"k$1": testKey1})
+		assert.Error(t, err)
+	})
+}
+
+func TestEncryptedColumns(t *testing.T) {
+	ctx := context.Background()
+	setTestCipher(t, "k1")
+	db := sqlhelptest.InitSqliteDB(t)
+	ddl, err := CreateTableSQL[encryptedStruct](DialectSQLite, "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, ddl, "ssn TEXT")
+	assert.Contains(t, ddl, "blob TEXT")
+	db.MustExec(ddl)
+
+	note := "note"
+	rec := encryptedStruct{SSN: "123-45-6789", Email: "a@example.com", Note: &note, Blob: []byte{0, 1, 2}}
+	id, err := Insert(ctx, db, "secrets", rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.ID = id
+
+	t.Run("stored encrypted", func(t *testing.T) {
+		var ssn string
+		assert.NoError(t, db.Get(&ssn, "SELECT ssn FROM secrets WHERE id = ?", id))
+		assert.NotContains(t, ssn, "6789")
+		assert.Contains(t, ssn, "k1$")
+	})
+	t.Run("select row", func(t *testing.T) {
+		got, err := SelectRow[encryptedStruct](ctx, db, "secrets", sq.Eq{"id": id})
+		if assert.NoError(t, err) {
+			assert.Equal(t, rec, *got)
+		}
+	})
+	t.Run("lookup", func(t *testing.T) {
+		it, err := Select[encryptedStruct](ctx, db, "secrets", EqEncrypted("email", "a@example.com"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := Collect2(it)
+		assert.NoError(t, err)
+		assert.Len(t, got, 1)
+	})
+	t.Run("null", func(t *testing.T) {
+		id, err := Insert(ctx, db, "secrets", encryptedStruct{SSN: "x"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := SelectRow[encryptedStruct](ctx, db, "secrets", sq.Eq{"id": id})
+		if assert.NoError(t, err) {
+			assert.Nil(t, got.Note)
+			assert.Nil(t, got.Blob)
+		}
+	})
+	t.Run("no cipher", func(t *testing.T) {
+		ColumnCipher = nil
+		_, err := Insert(ctx, db, "secrets", rec)
+		assert.ErrorIs(t, err, ErrNoCipher)
+	})
+}
@@ -0,0 +1,190 @@
+package sqlhelp
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// In this file: struct field to column mapping.  Fields are mapped to columns
+// using the [Tag] struct tag, the tag value is the column name, optionally
+// followed by comma separated options, i.e.:
+//
+//	ID   int64  `db:"id,pk,omitempty"`
+//	Name string `db:"name,notnull,default='unnamed'"`
+//
+// Anonymous and named nested structs are flattened into the parent, unless
+// they have the "json" option.
+
+// tagOptions is the list of options that follow the column name in the tag.
+type tagOptions []string
+
+// parseTag splits the tag value into the column name and options.
+func parseTag(tag string) (string, tagOptions) {
+	name, opts, found := strings.Cut(tag, ",")
+	if !found {
+		return name, nil
+	}
+	return name, tagOptions(strings.Split(opts, ","))
+}
+
+// has returns true if the option is present.
+func (o tagOptions) has(opt string) bool {
+	return slices.Contains(o, opt)
+}
+
+// value returns the value of the "opt=value" option.
+func (o tagOptions) value(opt string) (string, bool) {
+	for _, s := range o {
+		if k, v, found := strings.Cut(s, "="); found && k == opt {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// field is a struct field mapped to the column.
+type field struct {
+	name   string       // Go field name
+	column string       // column name
+	index  []int        // index sequence for reflect.Value.FieldByIndex
+	offset uintptr      // offset within the outermost struct
+	typ    reflect.Type // field type
+	opts   tagOptions   // tag options
+}
+
+// fieldCache caches the fields of struct types, keyed by tag and type.
+var fieldCache sync.Map
+
+type fieldCacheKey struct {
+	tag string
+	typ reflect.Type
+}
+
+// fieldsOf returns the mapped fields of the struct type t, in the order of
+// declaration.  t must be a struct type.
+func fieldsOf(t reflect.Type) []field {
+	key := fieldCacheKey{Tag, t}
+	if ff, ok := fieldCache.Load(key); ok {
+		return ff.([]field)
+	}
+	ff := walkFields(t, nil, 0)
+	fieldCache.Store(key, ff)
+	return ff
+}
+
+func walkFields(t reflect.Type, index []int, offset uintptr) []field {
+	var ff []field
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name, opts := parseTag(sf.Tag.Get(Tag))
+		if name == "-" {
+			continue
+		}
+		idx := append(slices.Clip(index), i)
+		if sf.Type.Kind() == reflect.Struct && !isLeaf(sf.Type) && !opts.has("json") {
+			ff = append(ff, walkFields(sf.Type, idx, offset+sf.Offset)...)
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		ff = append(ff, field{name: sf.Name, column: name, index: idx, offset: offset + sf.Offset, typ: sf.Type, opts: opts})
+	}
+	return ff
+}
+
+var (
+	timeType    = reflect.TypeFor[time.Time]()
+	valuerType  = reflect.TypeFor[driver.Valuer]()
+	scannerType = reflect.TypeFor[sql.Scanner]()
+)
+
+// isLeaf returns true if the struct type t is stored in a single column, as
+// opposed to being flattened.
+func isLeaf(t reflect.Type) bool {
+	return t == timeType || t.Implements(valuerType) || reflect.PointerTo(t).Implements(scannerType)
+}
+
+// isScalar returns true if the values of type t are scanned from a single
+// column.
+func isScalar(t reflect.Type) bool {
+	return t.Kind() != reflect.Struct || isLeaf(t)
+}
+
+// toMap converts the struct a to a map[column]value.  If omitEmpty is true,
+// fields that have the "omitempty" option and an empty value (see isEmpty)
+// are skipped.  The option may follow other options, i.e.
+// `db:"id,pk,omitempty"`.  If a implements [ColumnMapper], it's used
+// instead.  Slices are encoded as arrays for the dialect d.
+func toMap(d Dialect, a any, omitEmpty bool) map[string]any {
+	if cm, ok := a.(ColumnMapper); ok {
+		m := cm.ToMap(omitEmpty)
+		for col, v := range m {
+			m[col] = encodeValue(d, v)
+		}
+		return m
+	}
+	v := reflect.Indirect(reflect.ValueOf(a))
+	ff := fieldsOf(v.Type())
+	m := make(map[string]any, len(ff))
+	for _, f := range ff {
+		fv := v.FieldByIndex(f.index)
+		if omitEmpty && f.opts.has("omitempty") && isEmpty(fv) {
+			continue
+		}
+		if f.opts.has("json") {
+			m[f.column] = JSONValue(fv.Interface())
+			continue
+		}
+		if enc, det := encryptOpt(f.opts); enc {
+			m[f.column] = Encrypted(fv.Interface(), det)
+			continue
+		}
+		m[f.column] = encodeValue(d, fv.Interface())
+	}
+	return m
+}
+
+// isEmpty returns true if v holds an empty value: zero number, false,
+// empty string, slice, array or map, nil pointer or interface, or zero
+// [time.Time].  Other structs, i.e. sql.NullString, are never empty, so that
+// the NULL value is stored explicitly.
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Struct:
+		return v.Type() == timeType && v.IsZero()
+	default:
+		return v.IsZero()
+	}
+}
+
+// IsEmpty returns true if v is empty in the sense of the "omitempty" tag
+// option.  It is used by the code generated with sqlhelpgen for the types
+// that can't be resolved statically.
+func IsEmpty(v any) bool {
+	if v == nil {
+		return true
+	}
+	return isEmpty(reflect.ValueOf(v))
+}
+
+// columnsOf returns the sorted list of columns for the struct type t.
+func columnsOf(t reflect.Type) []string {
+	ff := fieldsOf(t)
+	cols := make([]string, 0, len(ff))
+	for _, f := range ff {
+		cols = append(cols, f.column)
+	}
+	slices.Sort(cols)
+	return slices.Compact(cols)
+}
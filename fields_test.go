@@ -0,0 +1,75 @@
+package sqlhelp
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToMap(t *testing.T) {
+	type address struct {
+		City string `db:"city,omitempty"`
+	}
+	type record struct {
+		ID      int64          `db:"id,pk,omitempty"`
+		Name    string         `db:"name,omitempty"`
+		Note    sql.NullString `db:"note,omitempty"`
+		Created time.Time      `db:"created,omitempty"`
+		Ptr     *int           `db:"ptr,omitempty"`
+		Flag    bool           `db:"flag"`
+		Skip    string         `db:"-"`
+		Address address
+	}
+	one := 1
+	tests := []struct {
+		name      string
+		a         any
+		omitEmpty bool
+		want      map[string]any
+	}{
+		{
+			name:      "omitempty",
+			a:         record{},
+			omitEmpty: true,
+			want: map[string]any{
+				"note": sql.NullString{}, // zero structs are not empty
+				"flag": false,
+			},
+		},
+		{
+			name:      "omitempty disabled",
+			a:         record{},
+			omitEmpty: false,
+			want: map[string]any{
+				"id":      int64(0),
+				"name":    "",
+				"note":    sql.NullString{},
+				"created": time.Time{},
+				"ptr":     (*int)(nil),
+				"flag":    false,
+				"city":    "",
+			},
+		},
+		{
+			name:      "filled",
+			a:         &record{ID: 1, Name: "a", Created: testDate, Ptr: &one, Flag: true, Skip: "x", Address: address{City: "c"}},
+			omitEmpty: true,
+			want: map[string]any{
+				"id":      int64(1),
+				"name":    "a",
+				"note":    sql.NullString{},
+				"created": testDate,
+				"ptr":     &one,
+				"flag":    true,
+				"city":    "c",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, toMap(DialectPostgres, tt.a, tt.omitEmpty))
+		})
+	}
+}
@@ -6,7 +6,6 @@ require (
 	github.com/DATA-DOG/go-sqlmock v1.5.2
 	github.com/Masterminds/squirrel v1.5.4
 	github.com/jmoiron/sqlx v1.4.0
-	github.com/rusq/tagops v0.0.2
 	github.com/stretchr/testify v1.9.0
 	modernc.org/sqlite v1.33.1
 )
@@ -12,20 +12,26 @@ import (
 // primary key.
 
 // SelectRowByID selects a row by ID (assuming that ID column is named "id").
-func SelectRowByID[T any](ctx context.Context, db sqlx.ExtContext, table string, id int64) (*T, error) {
-	return SelectRow[T](ctx, db, table, sq.Eq{"id": id})
+func SelectRowByID[T any](ctx context.Context, db sqlx.ExtContext, table string, id int64, opts ...SelectOption) (*T, error) {
+	return SelectRow[T](ctx, db, table, sq.Eq{"id": id}, opts...)
 }
 
 // SelectRowByIntegrationID selects a row by integration_id (assuming that
 // there is an "integration_id" column).
-func SelectRowByIntegrationID[T any](ctx context.Context, db sqlx.ExtContext, table string, integrationID string) (*T, error) {
-	return SelectRow[T](ctx, db, table, sq.Eq{"integration_id": integrationID})
+func SelectRowByIntegrationID[T any](ctx context.Context, db sqlx.ExtContext, table string, integrationID string, opts ...SelectOption) (*T, error) {
+	return SelectRow[T](ctx, db, table, sq.Eq{"integration_id": integrationID}, opts...)
 }
 
+// DeleteByID deletes a record by ID.
 func DeleteByID(ctx context.Context, db sqlx.ExtContext, table string, id any) error {
 	return Delete(ctx, db, table, sq.Eq{"id": id})
 }
 
+// DeleteByIDOf deletes a record by ID from the table of T.
+func DeleteByIDOf[T any](ctx context.Context, db sqlx.ExtContext, id any) error {
+	return DeleteOf[T](ctx, db, sq.Eq{"id": id})
+}
+
 // UpdateByID updates a record by ID.
 func UpdateByID[T any](ctx context.Context, db sqlx.ExtContext, table string, id any, a *T) (int64, error) {
 	return Update(ctx, db, table, a, sq.Eq{"id": id})
@@ -35,3 +41,8 @@ func UpdateByID[T any](ctx context.Context, db sqlx.ExtContext, table string, id
 func ExistsByID(ctx context.Context, db sqlx.ExtContext, table string, id any) (bool, error) {
 	return Exists(ctx, db, table, sq.Eq{"id": id})
 }
+
+// ExistsByIDOf checks if a record with the given ID exists in the table of T.
+func ExistsByIDOf[T any](ctx context.Context, db sqlx.ExtContext, id any) (bool, error) {
+	return ExistsOf[T](ctx, db, sq.Eq{"id": id})
+}
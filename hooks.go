@@ -0,0 +1,102 @@
+package sqlhelp
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// In this file: lifecycle hooks.  Model types may implement any of the hook
+// interfaces below, on the value or the pointer receiver, and the helpers
+// call them regardless of the helper used.  If a "before" hook returns an
+// error, the operation is aborted.
+
+// BeforeInserter is called by the insert helpers before the record is
+// inserted.  It may modify the record.
+type BeforeInserter interface {
+	BeforeInsert(ctx context.Context) error
+}
+
+// AfterInserter is called by the insert helpers after the record is
+// inserted.
+type AfterInserter interface {
+	AfterInsert(ctx context.Context) error
+}
+
+// BeforeUpdater is called by the update helpers before the record is
+// updated.  It may modify the record.
+type BeforeUpdater interface {
+	BeforeUpdate(ctx context.Context) error
+}
+
+// AfterSelecter is called by the select and query helpers after the record
+// is scanned.
+type AfterSelecter interface {
+	AfterSelect(ctx context.Context) error
+}
+
+// BeforeDeleter is called by [DeleteOf] and [DeleteByIDOf] on the zero value
+// of T, before the records matching where are deleted.
+type BeforeDeleter interface {
+	BeforeDelete(ctx context.Context, where sq.Sqlizer) error
+}
+
+// hookOf returns the hook H, if a, or the value it points to, implements it.
+func hookOf[H any, T any](a *T) (H, bool) {
+	if h, ok := any(a).(H); ok {
+		return h, true
+	}
+	if v := reflect.ValueOf(*a); v.Kind() == reflect.Pointer && v.IsNil() {
+		return *new(H), false
+	}
+	h, ok := any(*a).(H)
+	return h, ok
+}
+
+func beforeInsert[T any](ctx context.Context, a *T) error {
+	if h, ok := hookOf[BeforeInserter](a); ok {
+		if err := h.BeforeInsert(ctx); err != nil {
+			return fmt.Errorf("BeforeInsert: %w", err)
+		}
+	}
+	return nil
+}
+
+func afterInsert[T any](ctx context.Context, a *T) error {
+	if h, ok := hookOf[AfterInserter](a); ok {
+		if err := h.AfterInsert(ctx); err != nil {
+			return fmt.Errorf("AfterInsert: %w", err)
+		}
+	}
+	return nil
+}
+
+func beforeUpdate[T any](ctx context.Context, a *T) error {
+	if h, ok := hookOf[BeforeUpdater](a); ok {
+		if err := h.BeforeUpdate(ctx); err != nil {
+			return fmt.Errorf("BeforeUpdate: %w", err)
+		}
+	}
+	return nil
+}
+
+func afterSelect[T any](ctx context.Context, a *T) error {
+	if h, ok := hookOf[AfterSelecter](a); ok {
+		if err := h.AfterSelect(ctx); err != nil {
+			return fmt.Errorf("AfterSelect: %w", err)
+		}
+	}
+	return nil
+}
+
+func beforeDelete[T any](ctx context.Context, where sq.Sqlizer) error {
+	var a T
+	if h, ok := hookOf[BeforeDeleter](&a); ok {
+		if err := h.BeforeDelete(ctx, where); err != nil {
+			return fmt.Errorf("BeforeDelete: %w", err)
+		}
+	}
+	return nil
+}
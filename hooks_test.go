@@ -0,0 +1,156 @@
+package sqlhelp
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/rusq/sqlhelp/sqlhelptest"
+	"github.com/stretchr/testify/assert"
+)
+
+var errHook = errors.New("hook error")
+
+type hookedStruct struct {
+	ID   int64  `db:"id,omitempty"`
+	Name string `db:"name"`
+
+	fail     bool
+	inserted bool
+	selected bool
+}
+
+func (x *hookedStruct) BeforeInsert(context.Context) error {
+	if x.fail {
+		return errHook
+	}
+	x.Name = "before:" + x.Name
+	return nil
+}
+
+func (x *hookedStruct) AfterInsert(context.Context) error {
+	x.inserted = true
+	return nil
+}
+
+func (x *hookedStruct) BeforeUpdate(context.Context) error {
+	if x.fail {
+		return errHook
+	}
+	x.Name = "updated:" + x.Name
+	return nil
+}
+
+func (x *hookedStruct) AfterSelect(context.Context) error {
+	x.selected = true
+	return nil
+}
+
+func (hookedStruct) BeforeDelete(_ context.Context, where sq.Sqlizer) error {
+	if where == nil {
+		return errHook
+	}
+	return nil
+}
+
+func (hookedStruct) TableName() string { return "hooked" }
+
+func TestInsertHooks(t *testing.T) {
+	ctx := context.Background()
+	t.Run("before insert modifies the record", func(t *testing.T) {
+		db, mock := sqlhelptest.InitMockDB(t)
+		mock.ExpectExec(`INSERT INTO hooked \(name\) VALUES \(\$1\)`).
+			WithArgs("before:test").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		_, err := Insert(ctx, db, "", &hookedStruct{Name: "test"})
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("before insert error aborts", func(t *testing.T) {
+		db, mock := sqlhelptest.InitMockDB(t)
+		_, err := Insert(ctx, db, "", &hookedStruct{Name: "test", fail: true})
+		assert.ErrorIs(t, err, errHook)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("after insert", func(t *testing.T) {
+		db, mock := sqlhelptest.InitMockDB(t)
+		mock.ExpectQuery(`INSERT INTO hooked \(name\) VALUES \(\$1\) ON CONFLICT DO NOTHING RETURNING id`).
+			WithArgs("before:test").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		rec := &hookedStruct{Name: "test"}
+		_, err := InsertPSQL(ctx, db, "", "id", rec)
+		assert.NoError(t, err)
+		assert.True(t, rec.inserted)
+	})
+}
+
+func TestUpdateHooks(t *testing.T) {
+	ctx := context.Background()
+	t.Run("before update modifies the record", func(t *testing.T) {
+		db, mock := sqlhelptest.InitMockDB(t)
+		mock.ExpectExec(`UPDATE hooked SET name = \$1 WHERE id = \$2`).
+			WithArgs("updated:test", 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		_, err := Update(ctx, db, "", &hookedStruct{Name: "test"}, sq.Eq{"id": 1})
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("before update error aborts", func(t *testing.T) {
+		db, mock := sqlhelptest.InitMockDB(t)
+		_, err := Update(ctx, db, "", &hookedStruct{Name: "test", fail: true}, sq.Eq{"id": 1})
+		assert.ErrorIs(t, err, errHook)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestSelectHooks(t *testing.T) {
+	ctx := context.Background()
+	t.Run("select row", func(t *testing.T) {
+		db, mock := sqlhelptest.InitMockDB(t)
+		mock.ExpectQuery(`SELECT id, name FROM hooked WHERE id = \$1`).
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "test"))
+		got, err := SelectRow[hookedStruct](ctx, db, "", sq.Eq{"id": 1})
+		assert.NoError(t, err)
+		assert.True(t, got.selected)
+	})
+	t.Run("select", func(t *testing.T) {
+		db, mock := sqlhelptest.InitMockDB(t)
+		mock.ExpectQuery(`SELECT id, name FROM hooked`).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "a").AddRow(2, "b"))
+		it, err := Select[hookedStruct](ctx, db, "", nil)
+		assert.NoError(t, err)
+		got, err := Collect2(it)
+		assert.NoError(t, err)
+		if assert.Len(t, got, 2) {
+			assert.True(t, got[0].selected)
+			assert.True(t, got[1].selected)
+		}
+	})
+	t.Run("query one", func(t *testing.T) {
+		db, mock := sqlhelptest.InitMockDB(t)
+		mock.ExpectQuery(`SELECT id, name FROM hooked`).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "test"))
+		got, err := QueryOne[hookedStruct](ctx, db, "SELECT id, name FROM hooked")
+		assert.NoError(t, err)
+		assert.True(t, got.selected)
+	})
+}
+
+func TestDeleteHooks(t *testing.T) {
+	ctx := context.Background()
+	t.Run("before delete", func(t *testing.T) {
+		db, mock := sqlhelptest.InitMockDB(t)
+		mock.ExpectExec(`DELETE FROM hooked WHERE id = \$1`).
+			WithArgs(1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		assert.NoError(t, DeleteByIDOf[hookedStruct](ctx, db, 1))
+	})
+	t.Run("before delete error aborts", func(t *testing.T) {
+		db, mock := sqlhelptest.InitMockDB(t)
+		assert.ErrorIs(t, DeleteOf[hookedStruct](ctx, db, nil), errHook)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
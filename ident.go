@@ -0,0 +1,235 @@
+package sqlhelp
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// In this file: identifier validation and quoting.  Table and column names
+// are concatenated into the SQL by the query builder, so the helpers
+// validate them, and quote the ones that are reserved words or contain
+// characters other than letters, digits and underscores.  Simple names are
+// left as is, so that the case folding rules of the database still apply.
+
+// ErrInvalidIdent is returned when the table or column name is rejected.
+var ErrInvalidIdent = errors.New("invalid identifier")
+
+// Identifier validates the table or column name, that may be qualified,
+// i.e. "schema.table", and quotes the parts of it where necessary.  Parts
+// that are already quoted are left as is.
+func (d Dialect) Identifier(name string) (string, error) {
+	parts, err := splitIdent(d, name)
+	if err != nil {
+		return "", err
+	}
+	for i, p := range parts {
+		if !isQuoted(d, p) && needsQuoting(d, p) {
+			parts[i] = d.QuoteIdent(p)
+		}
+	}
+	return strings.Join(parts, "."), nil
+}
+
+// ValidateIdent returns an error if the table or column name is suspicious,
+// i.e. contains the quotes, semicolons, comments, whitespace or control
+// characters outside of the quoted parts.
+func ValidateIdent(name string) error {
+	_, err := splitIdent(DialectUnknown, name)
+	return err
+}
+
+// splitIdent splits the qualified name into parts and validates them.
+func splitIdent(d Dialect, name string) ([]string, error) {
+	if name == "" {
+		return nil, fmt.Errorf("%w: empty name", ErrInvalidIdent)
+	}
+	q := d.quoteChar()
+	var parts []string
+	for s := name; ; {
+		var part string
+		if s != "" && s[0] == q {
+			// quoted part, the quote is escaped by doubling it.
+			i := 1
+			for ; i < len(s); i++ {
+				if s[i] == q {
+					if i+1 < len(s) && s[i+1] == q {
+						i++
+						continue
+					}
+					break
+				}
+			}
+			if i >= len(s) {
+				return nil, fmt.Errorf("%w: unterminated quote in %q", ErrInvalidIdent, name)
+			}
+			part, s = s[:i+1], s[i+1:]
+			if part == string([]byte{q, q}) || strings.ContainsRune(part, 0) {
+				return nil, fmt.Errorf("%w: %q", ErrInvalidIdent, name)
+			}
+		} else {
+			i := strings.IndexByte(s, '.')
+			if i < 0 {
+				i = len(s)
+			}
+			part, s = s[:i], s[i:]
+			if !safePart(part) {
+				return nil, fmt.Errorf("%w: %q", ErrInvalidIdent, name)
+			}
+		}
+		parts = append(parts, part)
+		if s == "" {
+			break
+		}
+		if s[0] != '.' || len(parts) == 3 {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidIdent, name)
+		}
+		s = s[1:]
+	}
+	return parts, nil
+}
+
+// splitAnyIdent splits the name that may be quoted for any dialect, with
+// double quotes or MySQL backticks.
+func splitAnyIdent(name string) ([]string, error) {
+	parts, err := splitIdent(DialectUnknown, name)
+	if err != nil {
+		if parts, err := splitIdent(DialectMySQL, name); err == nil {
+			return parts, nil
+		}
+	}
+	return parts, err
+}
+
+// unquotePart removes the quotes from the identifier part.  It returns false
+// if the part is not quoted.
+func unquotePart(s string) (string, bool) {
+	if len(s) < 2 || (s[0] != '"' && s[0] != '`') {
+		return s, false
+	}
+	q := string(s[0])
+	return strings.ReplaceAll(s[1:len(s)-1], q+q, q), true
+}
+
+// storedIdent splits the qualified name into parts as they are stored by
+// the database: quoted parts are unquoted, and unquoted parts are folded to
+// lower case on Postgres.
+func storedIdent(d Dialect, name string) ([]string, error) {
+	parts, err := splitIdent(d, name)
+	if err != nil {
+		return nil, err
+	}
+	for i, p := range parts {
+		if s, quoted := unquotePart(p); quoted {
+			parts[i] = s
+		} else if d == DialectPostgres {
+			parts[i] = strings.ToLower(p)
+		}
+	}
+	return parts, nil
+}
+
+// safePart returns true if the unquoted part of the identifier is safe to
+// quote.
+func safePart(s string) bool {
+	if s == "" || strings.Contains(s, "--") || strings.Contains(s, "/*") || strings.Contains(s, "*/") {
+		return false
+	}
+	for _, r := range s {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(" \t;'\"`\\()[],", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// quoteChar returns the identifier quote character for the dialect.
+func (d Dialect) quoteChar() byte {
+	if d == DialectMySQL {
+		return '`'
+	}
+	return '"'
+}
+
+// isQuoted returns true if the identifier part is quoted.
+func isQuoted(d Dialect, s string) bool {
+	return len(s) >= 2 && s[0] == d.quoteChar()
+}
+
+// needsQuoting returns true if the identifier part is the reserved word, or
+// is not the simple identifier.
+func needsQuoting(d Dialect, s string) bool {
+	for i, r := range s {
+		switch {
+		case r == '_' || ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z'):
+		case i > 0 && '0' <= r && r <= '9':
+		default:
+			return true
+		}
+	}
+	upper := strings.ToUpper(s)
+	return reservedWords[upper] || (d == DialectMySQL && mysqlReservedWords[upper])
+}
+
+// reservedWords are the words reserved by Postgres and SQLite, that can't
+// be used as table or column names unquoted.
+var reservedWords = setOf(
+	"ALL", "ANALYSE", "ANALYZE", "AND", "ANY", "ARRAY", "AS", "ASC",
+	"ASYMMETRIC", "AUTHORIZATION", "BINARY", "BOTH", "CASE", "CAST", "CHECK",
+	"COLLATE", "COLLATION", "COLUMN", "CONCURRENTLY", "CONSTRAINT", "CREATE",
+	"CROSS", "CURRENT_CATALOG", "CURRENT_DATE", "CURRENT_ROLE",
+	"CURRENT_SCHEMA", "CURRENT_TIME", "CURRENT_TIMESTAMP", "CURRENT_USER",
+	"DEFAULT", "DEFERRABLE", "DELETE", "DESC", "DISTINCT", "DO", "DROP", "ELSE",
+	"END", "ESCAPE", "EXCEPT", "EXISTS", "FALSE", "FETCH", "FOR", "FOREIGN",
+	"FREEZE", "FROM", "FULL", "GRANT", "GROUP", "HAVING", "ILIKE", "IN",
+	"INDEX", "INITIALLY", "INNER", "INSERT", "INTERSECT", "INTO", "IS",
+	"ISNULL", "JOIN", "LATERAL", "LEADING", "LEFT", "LIKE", "LIMIT",
+	"LOCALTIME", "LOCALTIMESTAMP", "NATURAL", "NOT", "NOTNULL", "NULL",
+	"OFFSET", "ON", "ONLY", "OR", "ORDER", "OUTER", "OVERLAPS", "PLACING",
+	"PRIMARY", "REFERENCES", "RETURNING", "RIGHT", "SELECT", "SESSION_USER",
+	"SET", "SIMILAR", "SOME", "SYMMETRIC", "SYSTEM_USER", "TABLE",
+	"TABLESAMPLE", "THEN", "TO", "TRAILING", "TRUE", "UNION", "UNIQUE",
+	"UPDATE", "USER", "USING", "VALUES", "VARIADIC", "VERBOSE", "WHEN",
+	"WHERE", "WINDOW", "WITH",
+)
+
+// mysqlReservedWords are the additional words reserved by MySQL.
+var mysqlReservedWords = setOf(
+	"CONDITION", "DATABASE", "DUAL", "FUNCTION", "GROUPS", "KEY", "KEYS",
+	"RANGE", "RANK", "READ", "REPLACE", "ROW", "ROWS", "SCHEMA", "SHOW",
+	"SIGNAL", "WRITE",
+)
+
+func setOf(words ...string) map[string]bool {
+	m := make(map[string]bool, len(words))
+	for _, w := range words {
+		m[w] = true
+	}
+	return m
+}
+
+// quoteColumns returns the validated and quoted column names.
+func (d Dialect) quoteColumns(cols []string) ([]string, error) {
+	res := make([]string, len(cols))
+	for i, c := range cols {
+		q, err := d.Identifier(c)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = q
+	}
+	return res, nil
+}
+
+// quoteMap returns the column map with the validated and quoted keys.
+func (d Dialect) quoteMap(m map[string]any) (map[string]any, error) {
+	res := make(map[string]any, len(m))
+	for c, v := range m {
+		q, err := d.Identifier(c)
+		if err != nil {
+			return nil, err
+		}
+		res[q] = v
+	}
+	return res, nil
+}
@@ -0,0 +1,122 @@
+package sqlhelp
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/rusq/sqlhelp/sqlhelptest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDialect_Identifier(t *testing.T) {
+	tests := []struct {
+		name    string
+		d       Dialect
+		ident   string
+		want    string
+		wantErr bool
+	}{
+		{"simple", DialectPostgres, "users", "users", false},
+		{"mixed case", DialectPostgres, "Users", "Users", false},
+		{"qualified", DialectPostgres, "public.users", "public.users", false},
+		{"reserved", DialectPostgres, "user", `"user"`, false},
+		{"reserved qualified", DialectPostgres, "app.order", `app."order"`, false},
+		{"reserved upper", DialectSQLite, "ORDER", `"ORDER"`, false},
+		{"hyphen", DialectPostgres, "my-table", `"my-table"`, false},
+		{"leading digit", DialectPostgres, "1table", `"1table"`, false},
+		{"already quoted", DialectPostgres, `"my table"`, `"my table"`, false},
+		{"quoted with dot", DialectPostgres, `"a.b".c`, `"a.b".c`, false},
+		{"quoted escaped quote", DialectPostgres, `"a""b"`, `"a""b"`, false},
+		{"mysql key", DialectMySQL, "key", "`key`", false},
+		{"postgres key", DialectPostgres, "key", "key", false},
+		{"mysql quoted", DialectMySQL, "`my table`", "`my table`", false},
+		{"empty", DialectPostgres, "", "", true},
+		{"injection", DialectPostgres, "users; DROP TABLE users", "", true},
+		{"comment", DialectPostgres, "users--", "", true},
+		{"block comment", DialectPostgres, "users/**/", "", true},
+		{"stray quote", DialectPostgres, `users"`, "", true},
+		{"unterminated quote", DialectPostgres, `"users`, "", true},
+		{"empty quoted", DialectPostgres, `""`, "", true},
+		{"single quote", DialectPostgres, "users'", "", true},
+		{"parens", DialectPostgres, "count(*)", "", true},
+		{"empty part", DialectPostgres, "a..b", "", true},
+		{"too many parts", DialectPostgres, "a.b.c.d", "", true},
+		{"space", DialectPostgres, "users u", "", true},
+		{"control", DialectPostgres, "us\x00ers", "", true},
+		{"garbage after quote", DialectPostgres, `"a"b`, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.d.Identifier(tt.ident)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Identifier() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				assert.ErrorIs(t, err, ErrInvalidIdent)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestIdentQuoting(t *testing.T) {
+	ctx := context.Background()
+	type order struct {
+		ID    int64  `db:"id,pk,omitempty"`
+		User  string `db:"user"`
+		Group string `db:"group"`
+	}
+	t.Run("insert", func(t *testing.T) {
+		db, mock := sqlhelptest.InitMockDB(t)
+		mock.ExpectQuery(`INSERT INTO "order" \("group","user"\) VALUES \(\$1,\$2\) ON CONFLICT DO NOTHING RETURNING "order"`).
+			WithArgs("g", "u").
+			WillReturnRows(sqlmock.NewRows([]string{"order"}).AddRow(1))
+		_, err := InsertPSQL(ctx, db, "order", "order", order{User: "u", Group: "g"})
+		assert.NoError(t, err)
+	})
+	t.Run("select", func(t *testing.T) {
+		db, mock := sqlhelptest.InitMockDB(t)
+		mock.ExpectQuery(`SELECT "group", id, "user" FROM "order" WHERE id = \$1`).
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"group", "id", "user"}).AddRow("g", 1, "u"))
+		got, err := SelectRow[order](ctx, db, "order", sq.Eq{"id": 1})
+		if assert.NoError(t, err) {
+			assert.Equal(t, order{ID: 1, User: "u", Group: "g"}, *got)
+		}
+	})
+	t.Run("create table", func(t *testing.T) {
+		ddl, err := CreateTableSQL[order](DialectSQLite, "order")
+		assert.NoError(t, err)
+		assert.Contains(t, ddl, `CREATE TABLE "order" (`)
+		assert.Contains(t, ddl, `"user" TEXT`)
+	})
+	t.Run("rejected", func(t *testing.T) {
+		db, mock := sqlhelptest.InitMockDB(t)
+		_, err := Select[order](ctx, db, "order; DROP TABLE users", nil)
+		assert.ErrorIs(t, err, ErrInvalidIdent)
+		_, err = InsertPSQL(ctx, db, "order", "id; DROP TABLE users", order{})
+		assert.ErrorIs(t, err, ErrInvalidIdent)
+		_, err = SelectColumn[int](ctx, db, "order", "id FROM users --", nil)
+		assert.ErrorIs(t, err, ErrInvalidIdent)
+		assert.ErrorIs(t, Delete(ctx, db, "x'", nil), ErrInvalidIdent)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("sqlite round trip", func(t *testing.T) {
+		db := sqlhelptest.InitSqliteDB(t)
+		ddl, err := CreateTableSQL[order](DialectSQLite, "order")
+		if err != nil {
+			t.Fatal(err)
+		}
+		db.MustExec(ddl)
+		id, err := Insert(ctx, db, "order", order{User: "u", Group: "g"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := SelectRow[order](ctx, db, "order", sq.Eq{"id": id})
+		if assert.NoError(t, err) {
+			assert.Equal(t, "u", got.User)
+		}
+	})
+}
@@ -0,0 +1,134 @@
+package sqlhelp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"reflect"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+)
+
+// Join is a table participating in the [SelectJoin] query.
+type Join struct {
+	Table string // table name
+	Alias string // table alias, i.e. "u"
+	// Prefix is the tag of the struct field that receives the columns of the
+	// table, i.e. "user" for the field `db:"user"`.
+	Prefix string
+	// Type is the join type, i.e. "LEFT JOIN", defaults to "JOIN".  Ignored
+	// for the first table.
+	Type string
+	// On is the join condition, i.e. "p.user_id = u.id".  Ignored for the
+	// first table.
+	On string
+}
+
+// SelectJoin selects rows from the joined tables into the composite struct
+// T, that has a nested struct field for each of the tables.  The first table
+// goes into the FROM clause, and the others are joined to it.  Columns are
+// derived from the tags of the nested structs and selected with the prefix,
+// i.e. `u.id AS "user.id"`.  Example:
+//
+//	type UserPost struct {
+//		User User `db:"user"`
+//		Post Post `db:"post"`
+//	}
+//
+//	SelectJoin[UserPost](ctx, db, []Join{
+//		{Table: "users", Alias: "u", Prefix: "user"},
+//		{Table: "posts", Alias: "p", Prefix: "post", On: "p.user_id = u.id"},
+//	}, sq.Eq{"u.id": 1})
+//
+// Fields of the outer-joined tables should be nullable.
+func SelectJoin[T any](ctx context.Context, db sqlx.ExtContext, tables []Join, where sq.Sqlizer) (iter.Seq2[T, error], error) {
+	if len(tables) == 0 {
+		return nil, errors.New("no tables to select from")
+	}
+	d := DialectOf(db)
+	typ := reflect.TypeFor[T]()
+	var (
+		cols  []string
+		names = make([]string, len(tables)) // "table alias"
+	)
+	for i, j := range tables {
+		st, err := prefixedStruct(typ, j.Prefix)
+		if err != nil {
+			return nil, err
+		}
+		alias, err := d.Identifier(j.Alias)
+		if err != nil {
+			return nil, err
+		}
+		name, err := tableName(ctx, db, j.Table)
+		if err != nil {
+			return nil, err
+		}
+		names[i] = name + " " + alias
+		for _, col := range columnsOf(st) {
+			qcol, err := d.Identifier(col)
+			if err != nil {
+				return nil, err
+			}
+			cols = append(cols, alias+"."+qcol+" AS "+d.QuoteIdent(j.Prefix+"."+col))
+		}
+	}
+
+	where, err := tenantWhere(ctx, d, tables[0].Table, tables[0].Alias, where)
+	if err != nil {
+		return nil, err
+	}
+	bld := sq.Select(cols...).From(names[0])
+	for i, j := range tables[1:] {
+		joinType := j.Type
+		if joinType == "" {
+			joinType = "JOIN"
+		}
+		// the tenant condition of the joined tables goes into the ON clause,
+		// so that the outer joins are preserved.
+		on, args := j.On, []any(nil)
+		column, id, ok, err := tenantOf(ctx, j.Table)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			if column, err = d.Identifier(j.Alias + "." + column); err != nil {
+				return nil, err
+			}
+			on = "(" + on + ") AND " + column + " = ?"
+			args = append(args, id)
+		}
+		bld = bld.JoinClause(joinType+" "+names[i+1]+" ON "+on, args...)
+	}
+	query, args, err := bld.Where(where).ToSql()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.QueryxContext(ctx, db.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	return rowsIter[T](ctx, rows), nil
+}
+
+// prefixedStruct returns the struct type of the field of typ tagged with
+// prefix.
+func prefixedStruct(typ reflect.Type, prefix string) (reflect.Type, error) {
+	for i := range typ.NumField() {
+		sf := typ.Field(i)
+		if name, _ := parseTag(sf.Tag.Get(Tag)); name != prefix || !sf.IsExported() {
+			continue
+		}
+		ft := sf.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if ft.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("field %s is not a struct", sf.Name)
+		}
+		return ft, nil
+	}
+	return nil, fmt.Errorf("%s has no field tagged %q", typ, prefix)
+}
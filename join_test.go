@@ -0,0 +1,82 @@
+package sqlhelp
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/rusq/sqlhelp/sqlhelptest"
+	"github.com/stretchr/testify/assert"
+)
+
+type userPost struct {
+	User relUser `db:"user"`
+	Post relPost `db:"post"`
+}
+
+type userProfile struct {
+	User    relUser         `db:"user"`
+	Profile nullableProfile `db:"profile"`
+}
+
+type nullableProfile struct {
+	UserID sql.NullInt64  `db:"user_id"`
+	Bio    sql.NullString `db:"bio"`
+}
+
+var userPostJoin = []Join{
+	{Table: "users", Alias: "u", Prefix: "user"},
+	{Table: "posts", Alias: "p", Prefix: "post", On: "p.user_id = u.id"},
+}
+
+func TestSelectJoin(t *testing.T) {
+	ctx := context.Background()
+	t.Run("sql", func(t *testing.T) {
+		db, mock := sqlhelptest.InitMockDB(t)
+		mock.ExpectQuery(`SELECT u.id AS "user.id", u.name AS "user.name", p.id AS "post.id", p.title AS "post.title", p.user_id AS "post.user_id" ` +
+			`FROM users u JOIN posts p ON p.user_id = u.id WHERE u.id = \$1`).
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"user.id", "user.name", "post.id", "post.title", "post.user_id"}).
+				AddRow(1, "alice", 2, "second", 1))
+		it, err := SelectJoin[userPost](ctx, db, userPostJoin, sq.Eq{"u.id": 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := Collect2(it)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, []userPost{{
+			User: relUser{ID: 1, Name: "alice"},
+			Post: relPost{ID: 2, UserID: 1, Title: "second"},
+		}}, got)
+	})
+	t.Run("left join", func(t *testing.T) {
+		db := initRelDB(t)
+		it, err := SelectJoin[userProfile](ctx, db, []Join{
+			{Table: "users", Alias: "u", Prefix: "user"},
+			{Table: "profiles", Alias: "pr", Prefix: "profile", Type: "LEFT JOIN", On: "pr.user_id = u.id"},
+		}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := Collect2(it)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, []userProfile{
+			{User: relUser{ID: 1, Name: "alice"}},
+			{
+				User:    relUser{ID: 2, Name: "bob"},
+				Profile: nullableProfile{UserID: sql.NullInt64{Int64: 2, Valid: true}, Bio: sql.NullString{String: "bob bio", Valid: true}},
+			},
+		}, got)
+	})
+	t.Run("unknown prefix", func(t *testing.T) {
+		db, _ := sqlhelptest.InitMockDB(t)
+		_, err := SelectJoin[userPost](ctx, db, []Join{{Table: "users", Alias: "u", Prefix: "author"}}, sq.Eq{"u.id": 1})
+		assert.Error(t, err)
+	})
+}
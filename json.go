@@ -0,0 +1,211 @@
+package sqlhelp
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/reflectx"
+)
+
+// In this file: JSON columns.  Fields with the "json" tag option are
+// marshalled to JSON on insert and update, and unmarshalled on select, i.e.:
+//
+//	Meta map[string]any `db:"meta,json"`
+//
+// The column type is JSONB on Postgres and TEXT on SQLite.  Nested structs
+// with the "json" option are stored in a single column, and are not
+// flattened.
+
+// JSONValue returns the [driver.Valuer] that marshals v to JSON.  A nil v is
+// stored as NULL.
+func JSONValue(v any) driver.Valuer {
+	return jsonValue{v}
+}
+
+type jsonValue struct {
+	v any
+}
+
+func (j jsonValue) Value() (driver.Value, error) {
+	if v := reflect.ValueOf(j.v); !v.IsValid() || (isNillable(v.Kind()) && v.IsNil()) {
+		return nil, nil
+	}
+	b, err := json.Marshal(j.v)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// isNillable returns true if the values of kind k can be nil.
+func isNillable(k reflect.Kind) bool {
+	switch k {
+	case reflect.Pointer, reflect.Map, reflect.Slice, reflect.Interface:
+		return true
+	}
+	return false
+}
+
+// ScanJSON returns the [sql.Scanner] that unmarshals the JSON column value
+// into dest, which must be a pointer.  NULL resets dest to the zero value.
+func ScanJSON(dest any) sql.Scanner {
+	return jsonScanner{dest}
+}
+
+type jsonScanner struct {
+	dest any
+}
+
+func (j jsonScanner) Scan(src any) error {
+	var b []byte
+	switch s := src.(type) {
+	case nil:
+		v := reflect.ValueOf(j.dest).Elem()
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	case []byte:
+		b = s
+	case string:
+		b = []byte(s)
+	default:
+		return fmt.Errorf("cannot scan %T into JSON", src)
+	}
+	return json.Unmarshal(b, j.dest)
+}
+
+// needsFieldScan returns true if any of the fields of the struct type t has
+// the "json" or "encrypted" option, or is an array, and can't be scanned
+// with StructScan.
+func needsFieldScan(t reflect.Type) bool {
+	for _, f := range fieldsOf(t) {
+		if f.opts.has("json") || isArray(f.typ) {
+			return true
+		}
+		if enc, _ := encryptOpt(f.opts); enc {
+			return true
+		}
+	}
+	return false
+}
+
+// mappers caches the field mappers, keyed by tag.
+var mappers sync.Map
+
+// fieldMapper returns the sqlx field mapper for the [Tag].
+func fieldMapper() *reflectx.Mapper {
+	if m, ok := mappers.Load(Tag); ok {
+		return m.(*reflectx.Mapper)
+	}
+	m, _ := mappers.LoadOrStore(Tag, reflectx.NewMapperFunc(Tag, sqlx.NameMapper))
+	return m.(*reflectx.Mapper)
+}
+
+// scanFields scans the row into the struct pointed to by dest, decoding the
+// fields with the "json" and "encrypted" options and the arrays.  Column names are resolved
+// the same way as in StructScan.
+func scanFields(r rowScanner, dest any) error {
+	cols, err := r.Columns()
+	if err != nil {
+		return err
+	}
+	v := reflect.ValueOf(dest).Elem()
+	m := fieldMapper()
+	tm := m.TypeMap(v.Type())
+	vals := make([]any, len(cols))
+	for i, idx := range m.TraversalsByName(v.Type(), cols) {
+		if len(idx) == 0 {
+			return fmt.Errorf("missing destination name %s in %T", cols[i], dest)
+		}
+		fv := reflectx.FieldByIndexes(v, idx)
+		opts := tm.GetByTraversal(idx).Options
+		if _, ok := opts["json"]; ok {
+			vals[i] = ScanJSON(fv.Addr().Interface())
+		} else if _, ok := opts["encrypted"]; ok {
+			vals[i] = ScanEncrypted(fv.Addr().Interface())
+		} else if isArray(fv.Type()) {
+			vals[i] = ScanArray(fv.Addr().Interface())
+		} else {
+			vals[i] = fv.Addr().Interface()
+		}
+	}
+	return r.Scan(vals...)
+}
+
+// JSONPath is the value at the path within the JSON column, for use in the
+// where clauses, i.e.:
+//
+//	JSONField(DialectPostgres, "meta", "address", "city").Eq("Wellington")
+//
+// On Postgres the value is extracted as text with the #>> operator, so the
+// arguments are compared as text.  On SQLite, json_extract is used, and the
+// values are compared as their JSON types.
+type JSONPath struct {
+	Dialect Dialect
+	Column  string
+	Path    []string
+}
+
+// JSONField returns the JSONPath for the column and path.
+func JSONField(d Dialect, column string, path ...string) JSONPath {
+	return JSONPath{Dialect: d, Column: column, Path: path}
+}
+
+// expr returns the SQL expression that extracts the value and its argument.
+func (p JSONPath) expr() (string, any, error) {
+	col, err := p.Dialect.Identifier(p.Column)
+	if err != nil {
+		return "", nil, err
+	}
+	switch p.Dialect {
+	case DialectPostgres:
+		return col + " #>> ?", "{" + strings.Join(p.Path, ",") + "}", nil
+	case DialectSQLite:
+		var buf strings.Builder
+		buf.WriteString("$")
+		for _, el := range p.Path {
+			buf.WriteString(`."` + el + `"`)
+		}
+		return "json_extract(" + col + ", ?)", buf.String(), nil
+	}
+	return "", nil, fmt.Errorf("%w: %s", ErrUnsupportedDialect, p.Dialect)
+}
+
+// jsonCond is the condition on the JSON path.
+type jsonCond struct {
+	path JSONPath
+	op   string // operator and the placeholder, if any
+	args []any
+}
+
+func (c jsonCond) ToSql() (string, []any, error) {
+	expr, arg, err := c.path.expr()
+	if err != nil {
+		return "", nil, err
+	}
+	return expr + " " + c.op, append([]any{arg}, c.args...), nil
+}
+
+// Eq returns the "path = v" condition.
+func (p JSONPath) Eq(v any) sq.Sqlizer { return jsonCond{p, "= ?", []any{v}} }
+
+// NotEq returns the "path <> v" condition.
+func (p JSONPath) NotEq(v any) sq.Sqlizer { return jsonCond{p, "<> ?", []any{v}} }
+
+// Like returns the "path LIKE pattern" condition.
+func (p JSONPath) Like(pattern string) sq.Sqlizer {
+	return jsonCond{p, "LIKE ?", []any{pattern}}
+}
+
+// IsNull returns the "path IS NULL" condition, that is true if the path
+// does not exist or holds null.
+func (p JSONPath) IsNull() sq.Sqlizer { return jsonCond{p, "IS NULL", nil} }
+
+// IsNotNull returns the "path IS NOT NULL" condition.
+func (p JSONPath) IsNotNull() sq.Sqlizer { return jsonCond{p, "IS NOT NULL", nil} }
@@ -0,0 +1,150 @@
+package sqlhelp
+
+import (
+	"context"
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/rusq/sqlhelp/sqlhelptest"
+	"github.com/stretchr/testify/assert"
+)
+
+type jsonAddress struct {
+	City string `json:"city"`
+	Zip  string `json:"zip"`
+}
+
+type jsonStruct struct {
+	ID      int64             `db:"id,pk,omitempty"`
+	Name    string            `db:"name"`
+	Meta    map[string]string `db:"meta,json"`
+	Address jsonAddress       `db:"address,json"`
+	Tags    []string          `db:"tags,json"`
+}
+
+func TestJSONColumns(t *testing.T) {
+	ctx := context.Background()
+	db := sqlhelptest.InitSqliteDB(t)
+	ddl, err := CreateTableSQL[jsonStruct](DialectSQLite, "docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, ddl, "meta TEXT")
+	assert.Contains(t, ddl, "address TEXT")
+	db.MustExec(ddl)
+
+	rec := jsonStruct{
+		Name:    "one",
+		Meta:    map[string]string{"k": "v"},
+		Address: jsonAddress{City: "Wellington", Zip: "6011"},
+		Tags:    []string{"a", "b"},
+	}
+	if _, err := Insert(ctx, db, "docs", rec); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Insert(ctx, db, "docs", jsonStruct{Name: "two", Address: jsonAddress{City: "Auckland"}}); err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, VerifySchema[jsonStruct](ctx, db, "docs"))
+
+	t.Run("select row", func(t *testing.T) {
+		got, err := SelectRow[jsonStruct](ctx, db, "docs", sq.Eq{"name": "one"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		rec.ID = got.ID
+		assert.Equal(t, rec, *got)
+	})
+	t.Run("null json", func(t *testing.T) {
+		got, err := SelectRow[jsonStruct](ctx, db, "docs", sq.Eq{"name": "two"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Nil(t, got.Meta)
+		assert.Nil(t, got.Tags)
+		assert.Equal(t, "Auckland", got.Address.City)
+	})
+	t.Run("json path filter", func(t *testing.T) {
+		it, err := Select[jsonStruct](ctx, db, "docs", JSONField(DialectSQLite, "address", "city").Eq("Wellington"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := Collect2(it)
+		assert.NoError(t, err)
+		if assert.Len(t, got, 1) {
+			assert.Equal(t, "one", got[0].Name)
+		}
+	})
+	t.Run("update", func(t *testing.T) {
+		upd := jsonStruct{Name: "one", Meta: map[string]string{"k": "w"}}
+		if _, err := Update(ctx, db, "docs", &upd, sq.Eq{"name": "one"}); err != nil {
+			t.Fatal(err)
+		}
+		ok, err := Exists(ctx, db, "docs", JSONField(DialectSQLite, "meta", "k").Eq("w"))
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+}
+
+func TestJSONPath(t *testing.T) {
+	tests := []struct {
+		name     string
+		cond     sq.Sqlizer
+		wantSQL  string
+		wantArgs []any
+		wantErr  bool
+	}{
+		{
+			"postgres eq",
+			JSONField(DialectPostgres, "meta", "address", "city").Eq("Wellington"),
+			"meta #>> ? = ?",
+			[]any{"{address,city}", "Wellington"},
+			false,
+		},
+		{
+			"sqlite like",
+			JSONField(DialectSQLite, "meta", "name").Like("a%"),
+			"json_extract(meta, ?) LIKE ?",
+			[]any{`$."name"`, "a%"},
+			false,
+		},
+		{
+			"postgres is null",
+			JSONField(DialectPostgres, "meta", "x").IsNull(),
+			"meta #>> ? IS NULL",
+			[]any{"{x}"},
+			false,
+		},
+		{
+			"reserved word column",
+			JSONField(DialectPostgres, "user", "x").Eq(1),
+			`"user" #>> ? = ?`,
+			[]any{"{x}", 1},
+			false,
+		},
+		{
+			"invalid column",
+			JSONField(DialectSQLite, "meta)--", "x").Eq(1),
+			"",
+			nil,
+			true,
+		},
+		{
+			"unsupported dialect",
+			JSONField(DialectUnknown, "meta", "x").NotEq(1),
+			"",
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotSQL, gotArgs, err := tt.cond.ToSql()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ToSql() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			assert.Equal(t, tt.wantSQL, gotSQL)
+			assert.Equal(t, tt.wantArgs, gotArgs)
+		})
+	}
+}
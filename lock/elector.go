@@ -0,0 +1,124 @@
+package lock
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Elector is the lease-based leader election.  The instances that run the
+// Elector with the same name compete for the lease in the lock table, and
+// the one that holds it is the leader.  The leader renews the lease every
+// third of the TTL; if it fails to renew the lease before it expires, it
+// loses the leadership, and another instance takes over.
+type Elector struct {
+	l       *leaser
+	onError func(error)
+	leader  atomic.Bool
+}
+
+// ElectorOption is a functional option for the [Elector].
+type ElectorOption func(*Elector)
+
+// WithTTL sets the lease duration.  The default is [LeaseTTL].
+func WithTTL(d time.Duration) ElectorOption {
+	return func(e *Elector) {
+		e.l.ttl = d
+	}
+}
+
+// WithID sets the ID of the instance, that is stored as the owner of the
+// lease.  The default is the random ID.
+func WithID(id string) ElectorOption {
+	return func(e *Elector) {
+		e.l.owner = id
+	}
+}
+
+// WithErrorHandler sets the function that is called with the database
+// errors.  The election continues after the errors.
+func WithErrorHandler(fn func(error)) ElectorOption {
+	return func(e *Elector) {
+		e.onError = fn
+	}
+}
+
+// NewElector returns the Elector for the election with the name.
+// Postgres and SQLite are supported.
+func NewElector(db *sqlx.DB, name string, opts ...ElectorOption) (*Elector, error) {
+	l, err := newLeaser(db, name, newOwnerID(), LeaseTTL)
+	if err != nil {
+		return nil, err
+	}
+	e := &Elector{l: l}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e, nil
+}
+
+// ID returns the ID of the instance.
+func (e *Elector) ID() string {
+	return e.l.owner
+}
+
+// IsLeader returns true, if the instance is the leader.
+func (e *Elector) IsLeader() bool {
+	return e.leader.Load()
+}
+
+// Run campaigns for the leadership until ctx is cancelled.  When the
+// instance becomes the leader, Run calls lead, and keeps renewing the lease
+// while lead runs.  The context passed to lead is cancelled when the
+// leadership is lost, lead must return promptly then.  When lead returns,
+// the lease is released, and Run continues campaigning.
+//
+// Run returns the context error after ctx is cancelled and lead, if
+// running, has returned.
+func (e *Elector) Run(ctx context.Context, lead func(ctx context.Context)) error {
+	for {
+		ok, err := e.l.acquire(ctx)
+		if err != nil && ctx.Err() == nil {
+			e.error(err)
+		}
+		if ok {
+			e.lead(ctx, lead)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(e.l.ttl / 3):
+		}
+	}
+}
+
+// lead runs lead while holding the lease.
+func (e *Elector) lead(ctx context.Context, lead func(ctx context.Context)) {
+	e.leader.Store(true)
+	defer e.leader.Store(false)
+
+	lctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		lead(lctx)
+	}()
+	e.l.keepAlive(lctx, done, func() {
+		e.leader.Store(false)
+		cancel()
+	})
+	cancel()
+	<-done
+	if err := e.l.release(context.WithoutCancel(ctx)); err != nil {
+		e.error(err)
+	}
+}
+
+func (e *Elector) error(err error) {
+	if e.onError != nil {
+		e.onError(err)
+	}
+}
@@ -0,0 +1,101 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/rusq/sqlhelp"
+)
+
+// leaser holds the lease with the name in the lock table.
+type leaser struct {
+	db    *sqlx.DB
+	table string // quoted
+	name  string
+	owner string
+	ttl   time.Duration
+	dbNow string // expression for the current database time in Unix ms
+	now   func() time.Time
+
+	renewed time.Time // last successful acquire or renewal
+}
+
+func newLeaser(db *sqlx.DB, name, owner string, ttl time.Duration) (*leaser, error) {
+	d := sqlhelp.DialectOf(db)
+	if d != sqlhelp.DialectPostgres && d != sqlhelp.DialectSQLite {
+		return nil, fmt.Errorf("%w: %s", sqlhelp.ErrUnsupportedDialect, db.DriverName())
+	}
+	table, err := d.Identifier(Table)
+	if err != nil {
+		return nil, err
+	}
+	return &leaser{db: db, table: table, name: name, owner: owner, ttl: ttl, dbNow: dbNowExpr[d], now: time.Now}, nil
+}
+
+// dbNowExpr is the expression for the current database time in Unix
+// milliseconds.  Lease expiry is computed from the database time, so that
+// instances with skewed clocks can't take over a live lease.
+var dbNowExpr = map[sqlhelp.Dialect]string{
+	sqlhelp.DialectPostgres: "CAST(EXTRACT(EPOCH FROM now()) * 1000 AS BIGINT)",
+	sqlhelp.DialectSQLite:   "CAST(unixepoch('subsec') * 1000 AS INTEGER)",
+}
+
+// acquire takes or renews the lease.  It returns false, if the lease is
+// held by another owner and has not expired.
+func (l *leaser) acquire(ctx context.Context) (bool, error) {
+	now := l.now()
+	res, err := l.db.ExecContext(ctx, l.db.Rebind(
+		"INSERT INTO "+l.table+" AS l (name, owner, expires_at) VALUES (?, ?, "+l.dbNow+" + ?) "+
+			"ON CONFLICT (name) DO UPDATE SET owner = excluded.owner, expires_at = excluded.expires_at "+
+			"WHERE l.owner = excluded.owner OR l.expires_at <= "+l.dbNow),
+		l.name, l.owner, l.ttl.Milliseconds())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if n == 0 {
+		return false, nil
+	}
+	l.renewed = now
+	return true, nil
+}
+
+// expired returns true, if the lease could have expired, because it was
+// not renewed in time.  It only measures the local time elapsed since the
+// last renewal, and does not depend on the clocks of other instances.
+func (l *leaser) expired() bool {
+	return !l.now().Before(l.renewed.Add(l.ttl))
+}
+
+// release releases the lease, if it is held by the owner.
+func (l *leaser) release(ctx context.Context) error {
+	_, err := l.db.ExecContext(ctx, l.db.Rebind("DELETE FROM "+l.table+" WHERE name = ? AND owner = ?"), l.name, l.owner)
+	return err
+}
+
+// keepAlive renews the lease every third of the TTL, until ctx is cancelled
+// or done is closed.  It calls lost, if the lease is taken by another owner
+// or has expired.
+func (l *leaser) keepAlive(ctx context.Context, done <-chan struct{}, lost func()) {
+	t := time.NewTicker(l.ttl / 3)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-done:
+			return
+		case <-t.C:
+		}
+		ok, err := l.acquire(ctx)
+		if (err == nil && !ok) || (err != nil && l.expired()) {
+			lost()
+			return
+		}
+	}
+}
@@ -0,0 +1,156 @@
+// Package lock provides the advisory locks and the lease-based leader
+// election, i.e. to make sure that only one instance runs the scheduled
+// job:
+//
+//	err := lock.WithAdvisoryLock(ctx, db, "nightly-report", func(ctx context.Context) error {
+//		return report.Generate(ctx)
+//	})
+//	if errors.Is(err, lock.ErrLocked) {
+//		// another instance is running the job.
+//	}
+//
+// On Postgres, the advisory locks are used.  On SQLite, the locks are the
+// leases in the lock table, create it with the statement returned by
+// [CreateTableSQL].  The leader election uses the lock table on both.
+package lock
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/rusq/sqlhelp"
+)
+
+// Table is the name of the lock table.
+var Table = "sqlhelp_locks"
+
+// LeaseTTL is the lease duration of the locks held in the lock table.  The
+// lease is renewed while the lock is held, so that the lock of the crashed
+// instance is released after the lease expires.
+var LeaseTTL = 30 * time.Second
+
+// ErrLocked is returned by [WithAdvisoryLock] when the lock is held by
+// another session.
+var ErrLocked = errors.New("lock is held by another session")
+
+// lease is the row of the lock table.  Expiry is stored as Unix
+// milliseconds.
+type lease struct {
+	Name      string `db:"name,pk"`
+	Owner     string `db:"owner,notnull"`
+	ExpiresAt int64  `db:"expires_at,notnull"`
+}
+
+// CreateTableSQL returns the CREATE TABLE statement for the lock table.
+func CreateTableSQL(d sqlhelp.Dialect) (string, error) {
+	return sqlhelp.CreateTableSQL[lease](d, Table)
+}
+
+// keyOf returns the advisory lock key for the lock name.
+func keyOf(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return int64(h.Sum64())
+}
+
+// newOwnerID returns the random owner ID.
+func newOwnerID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// WithAdvisoryLock runs fn while holding the lock with the name.  It does
+// not wait for the lock, if the lock is held by another session, it returns
+// [ErrLocked] without running fn.
+//
+// On Postgres, the session-level advisory lock is taken with
+// pg_try_advisory_lock.  On SQLite, the lease in the lock table is taken and
+// renewed while fn runs; if the renewal fails, the context passed to fn is
+// cancelled.
+func WithAdvisoryLock(ctx context.Context, db *sqlx.DB, name string, fn func(ctx context.Context) error) error {
+	switch d := sqlhelp.DialectOf(db); d {
+	case sqlhelp.DialectPostgres:
+		return withPGLock(ctx, db, name, fn)
+	case sqlhelp.DialectSQLite:
+		return withLeaseLock(ctx, db, name, fn)
+	default:
+		return fmt.Errorf("%w: %s", sqlhelp.ErrUnsupportedDialect, db.DriverName())
+	}
+}
+
+func withPGLock(ctx context.Context, db *sqlx.DB, name string, fn func(ctx context.Context) error) error {
+	// session-level lock belongs to the connection, so it must be released
+	// on the same one.
+	conn, err := db.Connx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	key := keyOf(name)
+	var ok bool
+	if err := conn.QueryRowxContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&ok); err != nil {
+		return fmt.Errorf("acquiring lock %q: %w", name, err)
+	}
+	if !ok {
+		return ErrLocked
+	}
+	defer conn.ExecContext(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", key)
+	return fn(ctx)
+}
+
+func withLeaseLock(ctx context.Context, db *sqlx.DB, name string, fn func(ctx context.Context) error) error {
+	l, err := newLeaser(db, name, newOwnerID(), LeaseTTL)
+	if err != nil {
+		return err
+	}
+	ok, err := l.acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("acquiring lock %q: %w", name, err)
+	}
+	if !ok {
+		return ErrLocked
+	}
+	defer l.release(context.WithoutCancel(ctx))
+
+	lctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	done := make(chan struct{})
+	defer close(done)
+	go l.keepAlive(lctx, done, cancel)
+	return fn(lctx)
+}
+
+// WithAdvisoryXactLock takes the lock with the name for the duration of the
+// transaction tx, waiting for it if necessary.  The lock is released when
+// the transaction commits or rolls back.
+//
+// On Postgres, pg_advisory_xact_lock is used.  On SQLite, the write
+// transactions are serialised by the database, and the function takes the
+// database write lock upfront, with the write to the lock table.
+func WithAdvisoryXactLock(ctx context.Context, tx *sqlx.Tx, name string) error {
+	switch d := sqlhelp.DialectOf(tx); d {
+	case sqlhelp.DialectPostgres:
+		if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", keyOf(name)); err != nil {
+			return fmt.Errorf("acquiring lock %q: %w", name, err)
+		}
+		return nil
+	case sqlhelp.DialectSQLite:
+		table, err := d.Identifier(Table)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE "+table+" SET name = name WHERE name = ?", name); err != nil {
+			return fmt.Errorf("acquiring lock %q: %w", name, err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: %s", sqlhelp.ErrUnsupportedDialect, tx.DriverName())
+	}
+}
@@ -0,0 +1,218 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/rusq/sqlhelp"
+	"github.com/rusq/sqlhelp/sqlhelptest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+)
+
+func initDB(t *testing.T) *sqlx.DB {
+	t.Helper()
+	db := sqlhelptest.InitSqliteDB(t)
+	stmt, err := CreateTableSQL(sqlhelp.DialectSQLite)
+	require.NoError(t, err)
+	db.MustExec(stmt)
+	return db
+}
+
+func TestWithAdvisoryLock_sqlite(t *testing.T) {
+	ctx := context.Background()
+	db := initDB(t)
+
+	ran := false
+	err := WithAdvisoryLock(ctx, db, "job", func(ctx context.Context) error {
+		ran = true
+		// the lock is held.
+		err := WithAdvisoryLock(ctx, db, "job", func(ctx context.Context) error {
+			t.Error("must not run")
+			return nil
+		})
+		assert.ErrorIs(t, err, ErrLocked)
+		// other locks are independent.
+		return WithAdvisoryLock(ctx, db, "other", func(ctx context.Context) error { return nil })
+	})
+	require.NoError(t, err)
+	assert.True(t, ran)
+
+	// released after fn returns, the error is passed through.
+	errTest := errors.New("test")
+	err = WithAdvisoryLock(ctx, db, "job", func(ctx context.Context) error { return errTest })
+	assert.ErrorIs(t, err, errTest)
+	var n int
+	require.NoError(t, db.Get(&n, "SELECT COUNT(*) FROM sqlhelp_locks"))
+	assert.Zero(t, n)
+}
+
+func TestWithAdvisoryLock_postgres(t *testing.T) {
+	ctx := context.Background()
+	key := keyOf("job")
+	t.Run("acquired", func(t *testing.T) {
+		db, mock := sqlhelptest.InitMockDB(t)
+		mock.ExpectQuery(`SELECT pg_try_advisory_lock\(\$1\)`).WithArgs(key).
+			WillReturnRows(sqlmock.NewRows([]string{"ok"}).AddRow(true))
+		mock.ExpectExec(`SELECT pg_advisory_unlock\(\$1\)`).WithArgs(key).WillReturnResult(sqlmock.NewResult(0, 0))
+		ran := false
+		err := WithAdvisoryLock(ctx, db, "job", func(ctx context.Context) error {
+			ran = true
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.True(t, ran)
+	})
+	t.Run("locked", func(t *testing.T) {
+		db, mock := sqlhelptest.InitMockDB(t)
+		mock.ExpectQuery(`SELECT pg_try_advisory_lock\(\$1\)`).WithArgs(key).
+			WillReturnRows(sqlmock.NewRows([]string{"ok"}).AddRow(false))
+		err := WithAdvisoryLock(ctx, db, "job", func(ctx context.Context) error {
+			t.Error("must not run")
+			return nil
+		})
+		assert.ErrorIs(t, err, ErrLocked)
+	})
+}
+
+func TestWithAdvisoryXactLock(t *testing.T) {
+	ctx := context.Background()
+	t.Run("postgres", func(t *testing.T) {
+		db, mock := sqlhelptest.InitMockDB(t)
+		mock.ExpectBegin()
+		mock.ExpectExec(`SELECT pg_advisory_xact_lock\(\$1\)`).WithArgs(keyOf("job")).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+		tx := db.MustBeginTx(ctx, nil)
+		assert.NoError(t, WithAdvisoryXactLock(ctx, tx, "job"))
+		assert.NoError(t, tx.Commit())
+	})
+	t.Run("sqlite", func(t *testing.T) {
+		db := initDB(t)
+		tx := db.MustBeginTx(ctx, nil)
+		assert.NoError(t, WithAdvisoryXactLock(ctx, tx, "job"))
+		assert.NoError(t, tx.Commit())
+	})
+}
+
+func TestLeaser(t *testing.T) {
+	ctx := context.Background()
+	db := initDB(t)
+
+	a, err := newLeaser(db, "leader", "a", time.Minute)
+	require.NoError(t, err)
+	b, err := newLeaser(db, "leader", "b", time.Minute)
+	require.NoError(t, err)
+	// b's clock is skewed, it must not matter.
+	b.now = func() time.Time { return time.Now().Add(time.Hour) }
+
+	ok, err := a.acquire(ctx)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = b.acquire(ctx)
+	require.NoError(t, err)
+	assert.False(t, ok, "lease is held by a")
+
+	// a renews, b still can't take it.
+	ok, err = a.acquire(ctx)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = b.acquire(ctx)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// a stops renewing, the lease expires and b takes over.
+	db.MustExec("UPDATE sqlhelp_locks SET expires_at = expires_at - ?", 2*time.Minute.Milliseconds())
+	ok, err = b.acquire(ctx)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = a.acquire(ctx)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// a's release does not affect b's lease.
+	require.NoError(t, a.release(ctx))
+	ok, err = a.acquire(ctx)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestLeaser_expired(t *testing.T) {
+	db := initDB(t)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	l, err := newLeaser(db, "leader", "a", time.Minute)
+	require.NoError(t, err)
+	l.now = func() time.Time { return now }
+
+	ok, err := l.acquire(context.Background())
+	require.NoError(t, err)
+	require.True(t, ok)
+	now = now.Add(50 * time.Second)
+	assert.False(t, l.expired())
+	now = now.Add(10 * time.Second)
+	assert.True(t, l.expired())
+}
+
+func TestElector_Run(t *testing.T) {
+	db := initDB(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	e, err := NewElector(db, "cron", WithTTL(60*time.Millisecond), WithID("a"), WithErrorHandler(func(err error) { t.Error(err) }))
+	require.NoError(t, err)
+	assert.Equal(t, "a", e.ID())
+
+	var (
+		terms atomic.Int32
+		lost  = make(chan struct{}, 10)
+	)
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- e.Run(ctx, func(ctx context.Context) {
+			terms.Add(1)
+			<-ctx.Done()
+			lost <- struct{}{}
+		})
+	}()
+	require.Eventually(t, e.IsLeader, time.Second, 5*time.Millisecond)
+
+	// another instance steals the lease, the leader is notified.
+	db.MustExec("UPDATE sqlhelp_locks SET owner = 'b', expires_at = ?", time.Now().Add(time.Hour).UnixMilli())
+	select {
+	case <-lost:
+	case <-time.After(time.Second):
+		t.Fatal("leadership loss was not notified")
+	}
+	assert.Eventually(t, func() bool { return !e.IsLeader() }, time.Second, 5*time.Millisecond)
+
+	// the lease is released by the other instance, a is re-elected.
+	db.MustExec("DELETE FROM sqlhelp_locks")
+	require.Eventually(t, e.IsLeader, time.Second, 5*time.Millisecond)
+	// lead runs in its own goroutine, after the leadership is taken.
+	assert.Eventually(t, func() bool { return terms.Load() == 2 }, time.Second, 5*time.Millisecond)
+
+	cancel()
+	select {
+	case err := <-runErr:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return")
+	}
+	assert.False(t, e.IsLeader())
+	var n int
+	require.NoError(t, db.Get(&n, "SELECT COUNT(*) FROM sqlhelp_locks"))
+	assert.Zero(t, n, "lease is released")
+}
+
+func TestNewElector_unsupported(t *testing.T) {
+	db, _, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = NewElector(sqlx.NewDb(db, "mysql"), "cron")
+	assert.ErrorIs(t, err, sqlhelp.ErrUnsupportedDialect)
+}
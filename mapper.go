@@ -0,0 +1,56 @@
+package sqlhelp
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// In this file: interfaces that allow types to map themselves to and from
+// columns without reflection.  The implementations are normally generated
+// by the sqlhelpgen command (see cmd/sqlhelpgen).
+
+// ColumnMapper is implemented by types that convert themselves to the map of
+// column names to values.  If the value passed to [Insert], [Update] and
+// their variants implements ColumnMapper, it is used instead of reflection.
+type ColumnMapper interface {
+	// ToMap returns the map of column names to values.  If omitEmpty is true,
+	// fields that have the "omitempty" tag option and an empty value must be
+	// omitted.
+	ToMap(omitEmpty bool) map[string]any
+}
+
+// RowScanner is implemented by types that scan themselves from the row.  If
+// the pointer to the type implements RowScanner, it is used by the select
+// and query helpers instead of reflection.
+type RowScanner interface {
+	// ScanRow scans the row with the given columns, calling scan with the
+	// destination for each of the columns, in order.
+	ScanRow(columns []string, scan func(dest ...any) error) error
+}
+
+// Tabler is implemented by types that know the name of their table.  The
+// helpers use the table name of T when the table argument is empty.
+type Tabler interface {
+	TableName() string
+}
+
+// ErrNoTable is returned when the table name is empty, and the type does not
+// implement [Tabler].
+var ErrNoTable = errors.New("no table name")
+
+// tableOf returns table, or the table name of the type T, if table is
+// empty.
+func tableOf[T any](table string) (string, error) {
+	if table != "" {
+		return table, nil
+	}
+	typ := reflect.TypeFor[T]()
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if t, ok := reflect.New(typ).Interface().(Tabler); ok {
+		return t.TableName(), nil
+	}
+	return "", fmt.Errorf("%w: %s does not implement Tabler", ErrNoTable, typ)
+}
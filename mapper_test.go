@@ -0,0 +1,128 @@
+package sqlhelp
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/rusq/sqlhelp/sqlhelptest"
+	"github.com/stretchr/testify/assert"
+)
+
+// mappedStruct mimics the code generated by sqlhelpgen.
+type mappedStruct struct {
+	ID      int64  `db:"id,omitempty"`
+	Name    string `db:"name"`
+	scanned bool
+}
+
+func (x mappedStruct) ToMap(omitEmpty bool) map[string]any {
+	m := make(map[string]any, 2)
+	if !omitEmpty || x.ID != 0 {
+		m["id"] = x.ID
+	}
+	m["name"] = "mapped:" + x.Name
+	return m
+}
+
+func (x *mappedStruct) ScanRow(columns []string, scan func(dest ...any) error) error {
+	dest := make([]any, len(columns))
+	for i, col := range columns {
+		switch col {
+		case "id":
+			dest[i] = &x.ID
+		case "name":
+			dest[i] = &x.Name
+		default:
+			dest[i] = new(any)
+		}
+	}
+	x.scanned = true
+	return scan(dest...)
+}
+
+func TestColumnMapper(t *testing.T) {
+	db, mock := sqlhelptest.InitMockDB(t)
+	mock.ExpectExec(`INSERT INTO test_table \(name\) VALUES \(\$1\)`).
+		WithArgs("mapped:test").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	_, err := Insert(context.Background(), db, "test_table", mappedStruct{Name: "test"})
+	assert.NoError(t, err)
+}
+
+func TestRowScanner(t *testing.T) {
+	ctx := context.Background()
+	t.Run("select row", func(t *testing.T) {
+		db, mock := sqlhelptest.InitMockDB(t)
+		mock.ExpectQuery(`SELECT id, name FROM test_table WHERE id = \$1`).
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "test"))
+		got, err := SelectRow[mappedStruct](ctx, db, "test_table", sq.Eq{"id": 1})
+		assert.NoError(t, err)
+		assert.Equal(t, &mappedStruct{ID: 1, Name: "test", scanned: true}, got)
+	})
+	t.Run("query with extra columns", func(t *testing.T) {
+		db, mock := sqlhelptest.InitMockDB(t)
+		mock.ExpectQuery(`SELECT \*`).
+			WillReturnRows(sqlmock.NewRows([]string{"name", "extra", "id"}).AddRow("test", 42, 1))
+		it, err := Query[mappedStruct](ctx, db, "SELECT * FROM test_table")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := Collect2(it)
+		assert.NoError(t, err)
+		assert.Equal(t, []mappedStruct{{ID: 1, Name: "test", scanned: true}}, got)
+	})
+}
+
+func (mappedStruct) TableName() string {
+	return "mapped_table"
+}
+
+func TestTabler(t *testing.T) {
+	ctx := context.Background()
+	t.Run("insert", func(t *testing.T) {
+		db, mock := sqlhelptest.InitMockDB(t)
+		mock.ExpectExec(`INSERT INTO mapped_table \(name\) VALUES \(\$1\)`).
+			WithArgs("mapped:test").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		_, err := Insert(ctx, db, "", mappedStruct{Name: "test"})
+		assert.NoError(t, err)
+	})
+	t.Run("select by id", func(t *testing.T) {
+		db, mock := sqlhelptest.InitMockDB(t)
+		mock.ExpectQuery(`SELECT id, name FROM mapped_table WHERE id = \$1`).
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "test"))
+		_, err := SelectRowByID[mappedStruct](ctx, db, "", 1)
+		assert.NoError(t, err)
+	})
+	t.Run("explicit table takes precedence", func(t *testing.T) {
+		db, mock := sqlhelptest.InitMockDB(t)
+		mock.ExpectExec(`UPDATE other_table SET id = \$1, name = \$2 WHERE id = \$3`).
+			WithArgs(1, "mapped:test", 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		_, err := UpdateByID(ctx, db, "other_table", 1, &mappedStruct{ID: 1, Name: "test"})
+		assert.NoError(t, err)
+	})
+	t.Run("delete and exists", func(t *testing.T) {
+		db, mock := sqlhelptest.InitMockDB(t)
+		mock.ExpectExec(`DELETE FROM mapped_table WHERE id = \$1`).
+			WithArgs(1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(`SELECT 1 as X FROM mapped_table WHERE id = \$1`).
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"x"}))
+		assert.NoError(t, DeleteByIDOf[mappedStruct](ctx, db, 1))
+		ok, err := ExistsByIDOf[mappedStruct](ctx, db, 1)
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+	t.Run("no table", func(t *testing.T) {
+		db, _ := sqlhelptest.InitMockDB(t)
+		_, err := Insert(ctx, db, "", filledStruct)
+		assert.ErrorIs(t, err, ErrNoTable)
+		assert.ErrorIs(t, DeleteOf[TestStruct](ctx, db, sq.Eq{"id": 1}), ErrNoTable)
+	})
+}
@@ -0,0 +1,412 @@
+// Package migrate provides a lightweight migration runner that applies
+// versioned SQL files from an [fs.FS], i.e. an embedded directory.
+//
+// Migration files are named "<version>_<name>.up.sql" and
+// "<version>_<name>.down.sql", where version is a positive integer, i.e.:
+//
+//	0001_create_users.up.sql
+//	0001_create_users.down.sql
+//	0002_add_email.up.sql
+//
+// Down migrations are optional.  Applied versions are recorded in the
+// migrations table (see [DefaultTable]).
+//
+// While migrations run, the Migrator holds an advisory lock on Postgres, so
+// that only one instance migrates the database at a time.  Each migration is
+// applied in its own transaction.  On SQLite, all migrations run in a single
+// exclusive transaction instead.
+package migrate
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/fs"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/rusq/sqlhelp"
+)
+
+// DefaultTable is the default name of the table that holds applied
+// migration versions.
+var DefaultTable = "schema_migrations"
+
+// Migration is a single versioned migration.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string // up SQL
+	Down    string // down SQL, may be empty
+}
+
+// Status is the status of the migration.
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator applies migrations to the database.
+type Migrator struct {
+	db         *sqlx.DB
+	table      string
+	dryRun     io.Writer
+	migrations []Migration // sorted by version
+}
+
+// Option is a functional option for the [Migrator].
+type Option func(*Migrator)
+
+// WithTable sets the name of the migrations table.
+func WithTable(table string) Option {
+	return func(m *Migrator) {
+		m.table = table
+	}
+}
+
+// WithDryRun makes the Migrator write the SQL that would be executed to w
+// instead of executing it.
+func WithDryRun(w io.Writer) Option {
+	return func(m *Migrator) {
+		m.dryRun = w
+	}
+}
+
+// ErrNoDown is returned when the migration being rolled back has no down
+// SQL.
+var ErrNoDown = errors.New("migration has no down SQL")
+
+var reFilename = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// New loads migrations from the root of fsys and returns the Migrator.  Use
+// [fs.Sub] if migrations live in a subdirectory.
+func New(db *sqlx.DB, fsys fs.FS, opts ...Option) (*Migrator, error) {
+	m := &Migrator{db: db, table: DefaultTable}
+	for _, opt := range opts {
+		opt(m)
+	}
+	table, err := sqlhelp.DialectOf(db).Identifier(m.table)
+	if err != nil {
+		return nil, err
+	}
+	m.table = table
+	migs, err := load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	m.migrations = migs
+	return m, nil
+}
+
+// load loads migrations from fsys.
+func load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int64]*Migration)
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		match := reFilename.FindStringSubmatch(e.Name())
+		if match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", e.Name(), err)
+		}
+		data, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = mig
+		} else if mig.Name != match[2] {
+			return nil, fmt.Errorf("%s: version %d is already used by %q", e.Name(), version, mig.Name)
+		}
+		if match[3] == "up" {
+			mig.Up = string(data)
+		} else {
+			mig.Down = string(data)
+		}
+	}
+	migs := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s: missing up SQL", mig.Version, mig.Name)
+		}
+		migs = append(migs, *mig)
+	}
+	slices.SortFunc(migs, func(a, b Migration) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+	return migs, nil
+}
+
+// Migrations returns the loaded migrations, sorted by version.
+func (m *Migrator) Migrations() []Migration {
+	return slices.Clone(m.migrations)
+}
+
+// Status returns the status of all migrations, sorted by version.  It does
+// not create the migrations table.  If it does not exist, all migrations are
+// reported as pending.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	conn, err := m.db.Connx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return m.status(ctx, conn)
+}
+
+// Up applies all pending migrations.  It returns the list of applied
+// migrations.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+	err := m.withLock(ctx, func(q querier, begin beginFunc) error {
+		st, err := m.status(ctx, q)
+		if err != nil {
+			return err
+		}
+		for _, s := range st {
+			if s.Applied {
+				continue
+			}
+			if err := m.apply(ctx, begin, s.Migration, true); err != nil {
+				return err
+			}
+			done = append(done, s.Migration)
+		}
+		return nil
+	})
+	if err != nil && sqlhelp.DialectOf(m.db) == sqlhelp.DialectSQLite {
+		// the shared transaction is rolled back, nothing is applied.
+		return nil, err
+	}
+	return done, err
+}
+
+// Down rolls back the last n applied migrations.  It returns the list of
+// rolled back migrations.
+func (m *Migrator) Down(ctx context.Context, n int) ([]Migration, error) {
+	var done []Migration
+	err := m.withLock(ctx, func(q querier, begin beginFunc) error {
+		st, err := m.status(ctx, q)
+		if err != nil {
+			return err
+		}
+		for i := len(st) - 1; i >= 0 && len(done) < n; i-- {
+			if !st[i].Applied {
+				continue
+			}
+			if st[i].Down == "" {
+				return fmt.Errorf("migration %d_%s: %w", st[i].Version, st[i].Name, ErrNoDown)
+			}
+			if err := m.apply(ctx, begin, st[i].Migration, false); err != nil {
+				return err
+			}
+			done = append(done, st[i].Migration)
+		}
+		return nil
+	})
+	if err != nil && sqlhelp.DialectOf(m.db) == sqlhelp.DialectSQLite {
+		// the shared transaction is rolled back, no migration is reverted.
+		return nil, err
+	}
+	return done, err
+}
+
+// querier is the subset of [sqlx.Conn] and [sqlx.Tx] methods used by the
+// Migrator.
+type querier interface {
+	sqlx.QueryerContext
+	sqlx.ExecerContext
+	Rebind(string) string
+}
+
+// beginFunc starts the transaction for a single migration.  The returned
+// function finishes it, committing if err is nil, or rolling back otherwise.
+type beginFunc func() (querier, func(err error) error, error)
+
+// withLock runs fn while holding the migration lock.
+func (m *Migrator) withLock(ctx context.Context, fn func(q querier, begin beginFunc) error) error {
+	conn, err := m.db.Connx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	switch sqlhelp.DialectOf(m.db) {
+	case sqlhelp.DialectPostgres:
+		key := m.lockKey()
+		if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", key); err != nil {
+			return fmt.Errorf("acquiring migration lock: %w", err)
+		}
+		defer conn.ExecContext(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", key)
+
+		if err := m.createTable(ctx, conn); err != nil {
+			return err
+		}
+		begin := func() (querier, func(error) error, error) {
+			tx, err := conn.BeginTxx(ctx, nil)
+			if err != nil {
+				return nil, nil, err
+			}
+			return tx, func(err error) error {
+				if err != nil {
+					return errors.Join(err, tx.Rollback())
+				}
+				return tx.Commit()
+			}, nil
+		}
+		return fn(conn, begin)
+	case sqlhelp.DialectSQLite:
+		if _, err := conn.ExecContext(ctx, "BEGIN EXCLUSIVE"); err != nil {
+			return fmt.Errorf("acquiring migration lock: %w", err)
+		}
+		err := m.createTable(ctx, conn)
+		if err == nil {
+			// migrations share the exclusive transaction.
+			begin := func() (querier, func(error) error, error) {
+				return conn, func(err error) error { return err }, nil
+			}
+			err = fn(conn, begin)
+		}
+		if err != nil {
+			_, rbErr := conn.ExecContext(context.WithoutCancel(ctx), "ROLLBACK")
+			return errors.Join(err, rbErr)
+		}
+		_, err = conn.ExecContext(ctx, "COMMIT")
+		return err
+	default:
+		return fmt.Errorf("%w: %s", sqlhelp.ErrUnsupportedDialect, m.db.DriverName())
+	}
+}
+
+// lockKey returns the advisory lock key for the migrations table.
+func (m *Migrator) lockKey() int64 {
+	h := fnv.New64a()
+	h.Write([]byte("sqlhelp/migrate:" + m.table))
+	return int64(h.Sum64())
+}
+
+// createTable creates the migrations table, if it does not exist.  In the
+// dry-run mode it does nothing.
+func (m *Migrator) createTable(ctx context.Context, q querier) error {
+	if m.dryRun != nil {
+		return nil
+	}
+	_, err := q.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+m.table+
+		" (version BIGINT PRIMARY KEY, name TEXT NOT NULL, applied_at TIMESTAMP NOT NULL)")
+	return err
+}
+
+// status returns the status of all migrations.
+func (m *Migrator) status(ctx context.Context, q querier) ([]Status, error) {
+	// the table is not created by Status, or in the dry-run mode.
+	ok, err := m.tableExists(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return m.statusOf(nil), nil
+	}
+	rows, err := q.QueryxContext(ctx, "SELECT version, applied_at FROM "+m.table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var (
+			version int64
+			at      time.Time
+		)
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return m.statusOf(applied), nil
+}
+
+// statusOf returns the status of all migrations given the application
+// times of the applied ones.
+func (m *Migrator) statusOf(applied map[int64]time.Time) []Status {
+	st := make([]Status, len(m.migrations))
+	for i, mig := range m.migrations {
+		at, ok := applied[mig.Version]
+		st[i] = Status{Migration: mig, Applied: ok, AppliedAt: at}
+	}
+	return st
+}
+
+// tableExists returns true if the migrations table exists.
+func (m *Migrator) tableExists(ctx context.Context, q querier) (bool, error) {
+	var ok bool
+	switch sqlhelp.DialectOf(m.db) {
+	case sqlhelp.DialectPostgres:
+		err := q.QueryRowxContext(ctx, "SELECT to_regclass($1) IS NOT NULL", m.table).Scan(&ok)
+		return ok, err
+	case sqlhelp.DialectSQLite:
+		parts := strings.Split(m.table, ".")
+		name := strings.Trim(parts[len(parts)-1], `"`)
+		err := q.QueryRowxContext(ctx, "SELECT COUNT(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = ? COLLATE NOCASE", name).Scan(&ok)
+		return ok, err
+	default:
+		return false, fmt.Errorf("%w: %s", sqlhelp.ErrUnsupportedDialect, m.db.DriverName())
+	}
+}
+
+// apply applies the migration mig, or rolls it back if up is false.
+func (m *Migrator) apply(ctx context.Context, begin beginFunc, mig Migration, up bool) error {
+	var (
+		stmt  = mig.Up
+		track = "INSERT INTO " + m.table + " (version, name, applied_at) VALUES (?, ?, ?)"
+		args  = []any{mig.Version, mig.Name, time.Now().UTC()}
+	)
+	if !up {
+		stmt = mig.Down
+		track = "DELETE FROM " + m.table + " WHERE version = ?"
+		args = args[:1]
+	}
+	if m.dryRun != nil {
+		_, err := fmt.Fprintf(m.dryRun, "-- %d_%s (%s)\n%s\n", mig.Version, mig.Name, direction(up), stmt)
+		return err
+	}
+
+	q, finish, err := begin()
+	if err != nil {
+		return err
+	}
+	if _, err = q.ExecContext(ctx, stmt); err == nil {
+		_, err = q.ExecContext(ctx, q.Rebind(track), args...)
+	}
+	if err := finish(err); err != nil {
+		return fmt.Errorf("migration %d_%s (%s): %w", mig.Version, mig.Name, direction(up), err)
+	}
+	return nil
+}
+
+func direction(up bool) string {
+	if up {
+		return "up"
+	}
+	return "down"
+}
@@ -0,0 +1,211 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/rusq/sqlhelp/sqlhelptest"
+	"github.com/stretchr/testify/assert"
+	_ "modernc.org/sqlite"
+)
+
+var testFS = fstest.MapFS{
+	"0001_create_users.up.sql":   {Data: []byte("CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT);")},
+	"0001_create_users.down.sql": {Data: []byte("DROP TABLE users;")},
+	"0002_add_email.up.sql":      {Data: []byte("ALTER TABLE users ADD COLUMN email TEXT;\nCREATE INDEX users_email ON users (email);")},
+	"0002_add_email.down.sql":    {Data: []byte("DROP INDEX users_email;\nALTER TABLE users DROP COLUMN email;")},
+	"0003_seed.up.sql":           {Data: []byte("INSERT INTO users (name, email) VALUES ('admin', 'admin@example.com');")},
+	"README.md":                  {Data: []byte("not a migration")},
+}
+
+func versions(migs []Migration) []int64 {
+	var vv []int64
+	for _, m := range migs {
+		vv = append(vv, m.Version)
+	}
+	return vv
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		fsys    fstest.MapFS
+		want    []int64
+		wantErr bool
+	}{
+		{"ok", testFS, []int64{1, 2, 3}, false},
+		{
+			"missing up",
+			fstest.MapFS{"0001_x.down.sql": {Data: []byte("SELECT 1")}},
+			nil,
+			true,
+		},
+		{
+			"duplicate version",
+			fstest.MapFS{
+				"0001_x.up.sql": {Data: []byte("SELECT 1")},
+				"0001_y.up.sql": {Data: []byte("SELECT 1")},
+			},
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := New(sqlhelptest.InitSqliteDB(t), tt.fsys)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("New() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if m != nil {
+				assert.Equal(t, tt.want, versions(m.Migrations()))
+			}
+		})
+	}
+}
+
+func TestMigrator(t *testing.T) {
+	ctx := context.Background()
+	db := sqlhelptest.InitSqliteDB(t)
+	m, err := New(db, testFS)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := m.Up(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []int64{1, 2, 3}, versions(applied))
+
+	var email string
+	if err := db.GetContext(ctx, &email, "SELECT email FROM users WHERE name = 'admin'"); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "admin@example.com", email)
+
+	// nothing to do
+	applied, err = m.Up(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, applied)
+
+	// migration 3 has no down SQL.
+	_, err = m.Down(ctx, 1)
+	assert.ErrorIs(t, err, ErrNoDown)
+
+	st, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range st {
+		assert.True(t, s.Applied, s.Name)
+		assert.False(t, s.AppliedAt.IsZero(), s.Name)
+	}
+}
+
+func TestMigrator_Status(t *testing.T) {
+	ctx := context.Background()
+	db := sqlhelptest.InitSqliteDB(t)
+	m, err := New(db, testFS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range st {
+		assert.False(t, s.Applied, s.Name)
+	}
+	assert.Len(t, st, 3)
+
+	var n int
+	if err := db.GetContext(ctx, &n, "SELECT COUNT(*) FROM sqlite_master WHERE name = 'schema_migrations'"); err != nil {
+		t.Fatal(err)
+	}
+	assert.Zero(t, n, "status must not create the migrations table")
+}
+
+func TestMigrator_Down(t *testing.T) {
+	ctx := context.Background()
+	db := sqlhelptest.InitSqliteDB(t)
+	fsys := fstest.MapFS{}
+	for name, f := range testFS {
+		if name != "0003_seed.up.sql" {
+			fsys[name] = f
+		}
+	}
+	m, err := New(db, fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	rolledBack, err := m.Down(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []int64{2}, versions(rolledBack))
+
+	st, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, st[0].Applied)
+	assert.False(t, st[1].Applied)
+}
+
+func TestMigrator_failure(t *testing.T) {
+	ctx := context.Background()
+	db := sqlhelptest.InitSqliteDB(t)
+	m, err := New(db, fstest.MapFS{
+		"0001_ok.up.sql":     {Data: []byte("CREATE TABLE t1 (id INTEGER);")},
+		"0002_broken.up.sql": {Data: []byte("CREATE TABLE;")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	applied, err := m.Up(ctx)
+	assert.Error(t, err)
+	assert.Empty(t, applied)
+
+	// exclusive transaction is rolled back as a whole.
+	st, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, st[0].Applied)
+}
+
+func TestMigrator_dryRun(t *testing.T) {
+	ctx := context.Background()
+	db := sqlhelptest.InitSqliteDB(t)
+	var buf bytes.Buffer
+	m, err := New(db, testFS, WithDryRun(&buf), WithTable("migrations"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	applied, err := m.Up(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []int64{1, 2, 3}, versions(applied))
+	assert.Contains(t, buf.String(), "-- 1_create_users (up)\nCREATE TABLE users")
+
+	var n int
+	if err := db.Get(&n, "SELECT COUNT(*) FROM sqlite_master WHERE name = 'migrations'"); err != nil {
+		t.Fatal(err)
+	}
+	assert.Zero(t, n, "dry run must not create the migrations table")
+
+	st, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range st {
+		assert.False(t, s.Applied, s.Name)
+	}
+}
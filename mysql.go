@@ -0,0 +1,94 @@
+package sqlhelp
+
+import (
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// MySQLConfig is the MySQL connection configuration for the
+// github.com/go-sql-driver/mysql driver, i.e.:
+//
+//	cfg := NewMySQLConfig("localhost:3306", "app")
+//	cfg.ParseTime = true
+//	// tcp(localhost:3306)/app?parseTime=true
+type MySQLConfig struct {
+	User     string
+	Password string
+	Net      string // network, defaults to "tcp"
+	Addr     string // address, i.e. "localhost:3306"
+	Database string
+	// ParseTime enables scanning of DATE and DATETIME columns into
+	// time.Time.
+	ParseTime bool
+	// Loc is the location for the time values, if not nil.
+	Loc *time.Location
+	// MultiStatements allows multiple statements in one query.
+	MultiStatements bool
+	// Params are the other parameters, i.e. charset.
+	Params map[string]string
+	Driver string // driver name, defaults to "mysql"
+	Pool
+}
+
+var _ Config = (*MySQLConfig)(nil)
+
+// NewMySQLConfig returns the config for the server address and database.
+func NewMySQLConfig(addr string, database string) *MySQLConfig {
+	return &MySQLConfig{Addr: addr, Database: database, Params: make(map[string]string)}
+}
+
+// DriverName returns the driver name.
+func (cfg *MySQLConfig) DriverName() string {
+	if cfg.Driver == "" {
+		return "mysql"
+	}
+	return cfg.Driver
+}
+
+// DSN returns the connection string in the
+// [user[:password]@][net[(addr)]]/dbname[?params] form.  Parameters are
+// sorted by name.
+func (cfg *MySQLConfig) DSN() string {
+	var buf strings.Builder
+	if cfg.User != "" || cfg.Password != "" {
+		buf.WriteString(cfg.User)
+		if cfg.Password != "" {
+			buf.WriteString(":" + cfg.Password)
+		}
+		buf.WriteByte('@')
+	}
+	network := cfg.Net
+	if network == "" {
+		network = "tcp"
+	}
+	buf.WriteString(network)
+	if cfg.Addr != "" {
+		buf.WriteString("(" + cfg.Addr + ")")
+	}
+	buf.WriteString("/" + cfg.Database)
+
+	params := maps.Clone(cfg.Params)
+	if params == nil {
+		params = make(map[string]string)
+	}
+	if cfg.ParseTime {
+		params["parseTime"] = "true"
+	}
+	if cfg.Loc != nil {
+		params["loc"] = cfg.Loc.String()
+	}
+	if cfg.MultiStatements {
+		params["multiStatements"] = "true"
+	}
+	if len(params) > 0 {
+		pairs := make([]string, 0, len(params))
+		for _, k := range slices.Sorted(maps.Keys(params)) {
+			pairs = append(pairs, k+"="+url.QueryEscape(params[k]))
+		}
+		buf.WriteString("?" + strings.Join(pairs, "&"))
+	}
+	return buf.String()
+}
@@ -0,0 +1,106 @@
+// Package outbox implements the transactional outbox: events are written to
+// the outbox table in the same transaction as the row changes, and the
+// [Relay] delivers them to the message broker afterwards, so that events are
+// published if, and only if, the transaction commits.
+//
+//	tx := db.MustBeginTx(ctx, nil)
+//	id, err := outbox.InsertPSQL(ctx, tx, "users", "id", user,
+//		outbox.Event{Topic: "user.created", Key: user.Email, Payload: payload})
+//	...
+//	tx.Commit()
+//
+// The relay delivers events at least once, in the order of their IDs, so
+// consumers must be idempotent.  Create the outbox table with the statement
+// returned by [CreateTableSQL].
+package outbox
+
+import (
+	"context"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+	"github.com/rusq/sqlhelp"
+)
+
+// Table is the name of the outbox table.
+var Table = "outbox"
+
+// Event is the event in the outbox.
+type Event struct {
+	ID        int64     `db:"id,pk,omitempty"`
+	Topic     string    `db:"topic,notnull"`
+	Key       string    `db:"event_key,notnull"` // i.e. the aggregate ID, for partitioning
+	Payload   []byte    `db:"payload"`
+	CreatedAt time.Time `db:"created_at,notnull"`
+}
+
+// CreateTableSQL returns the CREATE TABLE statement for the outbox table.
+func CreateTableSQL(d sqlhelp.Dialect) (string, error) {
+	return sqlhelp.CreateTableSQL[Event](d, Table)
+}
+
+// Enqueue writes the events to the outbox table within the transaction.
+// Zero CreatedAt is set to the current time.
+func Enqueue(ctx context.Context, tx *sqlx.Tx, events ...Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	name, err := sqlhelp.DialectOf(tx).Identifier(Table)
+	if err != nil {
+		return err
+	}
+	bld := sq.Insert(name).Columns("topic", "event_key", "payload", "created_at")
+	now := time.Now().UTC()
+	for _, e := range events {
+		if e.CreatedAt.IsZero() {
+			e.CreatedAt = now
+		}
+		bld = bld.Values(e.Topic, e.Key, e.Payload, e.CreatedAt)
+	}
+	stmt, args, err := bld.ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, tx.Rebind(stmt), args...)
+	return err
+}
+
+// Insert inserts the record with [sqlhelp.Insert], and enqueues the events,
+// within the transaction.
+func Insert[T any](ctx context.Context, tx *sqlx.Tx, table string, a T, events ...Event) (int64, error) {
+	id, err := sqlhelp.Insert(ctx, tx, table, a)
+	if err != nil {
+		return id, err
+	}
+	return id, Enqueue(ctx, tx, events...)
+}
+
+// InsertPSQL is a Postgres flavour of Insert, see [sqlhelp.InsertPSQL].
+func InsertPSQL[T any](ctx context.Context, tx *sqlx.Tx, table string, idCol string, a T, events ...Event) (int64, error) {
+	id, err := sqlhelp.InsertPSQL(ctx, tx, table, idCol, a)
+	if err != nil {
+		return id, err
+	}
+	return id, Enqueue(ctx, tx, events...)
+}
+
+// Update updates the records with [sqlhelp.Update], and enqueues the events,
+// within the transaction.  The events are enqueued even if no rows were
+// updated.
+func Update[T any](ctx context.Context, tx *sqlx.Tx, table string, a *T, where sq.Sqlizer, events ...Event) (int64, error) {
+	n, err := sqlhelp.Update(ctx, tx, table, a, where)
+	if err != nil {
+		return n, err
+	}
+	return n, Enqueue(ctx, tx, events...)
+}
+
+// Delete deletes the records with [sqlhelp.Delete], and enqueues the events,
+// within the transaction.
+func Delete(ctx context.Context, tx *sqlx.Tx, table string, where sq.Sqlizer, events ...Event) error {
+	if err := sqlhelp.Delete(ctx, tx, table, where); err != nil {
+		return err
+	}
+	return Enqueue(ctx, tx, events...)
+}
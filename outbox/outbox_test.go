@@ -0,0 +1,141 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+	"github.com/rusq/sqlhelp"
+	"github.com/rusq/sqlhelp/sqlhelptest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+)
+
+type user struct {
+	ID   int64  `db:"id,pk"`
+	Name string `db:"name"`
+}
+
+func initDB(t *testing.T) *sqlx.DB {
+	t.Helper()
+	db := sqlhelptest.InitSqliteDB(t)
+	for _, stmt := range []func() (string, error){
+		func() (string, error) { return CreateTableSQL(sqlhelp.DialectSQLite) },
+		func() (string, error) { return sqlhelp.CreateTableSQL[user](sqlhelp.DialectSQLite, "users") },
+	} {
+		s, err := stmt()
+		require.NoError(t, err)
+		db.MustExec(s)
+	}
+	return db
+}
+
+func topics(events []Event) []string {
+	var tt []string
+	for _, e := range events {
+		tt = append(tt, e.Topic)
+	}
+	return tt
+}
+
+func TestOutbox(t *testing.T) {
+	ctx := context.Background()
+	db := initDB(t)
+
+	// rolled back events are not published.
+	tx := db.MustBeginTx(ctx, nil)
+	_, err := Insert(ctx, tx, "users", user{Name: "ghost"}, Event{Topic: "user.created", Key: "ghost"})
+	require.NoError(t, err)
+	require.NoError(t, tx.Rollback())
+
+	tx = db.MustBeginTx(ctx, nil)
+	id, err := Insert(ctx, tx, "users", user{Name: "bob"}, Event{Topic: "user.created", Key: "bob", Payload: []byte(`{"id":1}`)})
+	require.NoError(t, err)
+	_, err = Update(ctx, tx, "users", &user{ID: id, Name: "robert"}, sq.Eq{"id": id}, Event{Topic: "user.renamed", Key: "bob"})
+	require.NoError(t, err)
+	require.NoError(t, Delete(ctx, tx, "users", sq.Eq{"id": id}, Event{Topic: "user.deleted", Key: "bob"}))
+	require.NoError(t, tx.Commit())
+
+	var published []Event
+	fail := true
+	r := NewRelay(db, func(ctx context.Context, e Event) error {
+		if e.Topic == "user.deleted" && fail {
+			fail = false
+			return errors.New("broker unavailable")
+		}
+		published = append(published, e)
+		return nil
+	}, WithBatchSize(10))
+
+	n, err := r.Process(ctx)
+	assert.Error(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []string{"user.created", "user.renamed"}, topics(published))
+	assert.Equal(t, []byte(`{"id":1}`), published[0].Payload)
+	assert.False(t, published[0].CreatedAt.IsZero())
+
+	// the failed event is retried.
+	n, err = r.Process(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []string{"user.created", "user.renamed", "user.deleted"}, topics(published))
+
+	n, err = r.Process(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+}
+
+func TestRelay_Process_postgres(t *testing.T) {
+	db, mock := sqlhelptest.InitMockDB(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, topic, event_key, payload, created_at FROM outbox ORDER BY id LIMIT 2 FOR UPDATE SKIP LOCKED`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "topic", "event_key", "payload", "created_at"}).
+			AddRow(1, "a", "k", nil, time.Now()).
+			AddRow(2, "b", "k", nil, time.Now()))
+	mock.ExpectExec(`DELETE FROM outbox WHERE id IN \(\$1,\$2\)`).WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	var got []int64
+	r := NewRelay(db, func(ctx context.Context, e Event) error {
+		got = append(got, e.ID)
+		return nil
+	}, WithBatchSize(2))
+	n, err := r.Process(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []int64{1, 2}, got)
+}
+
+func TestRelay_Process_unsupported(t *testing.T) {
+	db, _, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	r := NewRelay(sqlx.NewDb(db, "mysql"), func(ctx context.Context, e Event) error { return nil })
+	_, err = r.Process(context.Background())
+	assert.ErrorIs(t, err, sqlhelp.ErrUnsupportedDialect)
+}
+
+func TestRelay_Run(t *testing.T) {
+	db := initDB(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	tx := db.MustBeginTx(ctx, nil)
+	require.NoError(t, Enqueue(ctx, tx, Event{Topic: "a"}, Event{Topic: "b"}, Event{Topic: "c"}))
+	require.NoError(t, tx.Commit())
+
+	var published []Event
+	r := NewRelay(db, func(ctx context.Context, e Event) error {
+		published = append(published, e)
+		if len(published) == 3 {
+			cancel()
+		}
+		return nil
+	}, WithBatchSize(2))
+	err := r.Run(ctx, func(err error) { t.Error(err) })
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, []string{"a", "b", "c"}, topics(published))
+}
@@ -0,0 +1,143 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+	"github.com/rusq/sqlhelp"
+)
+
+// PublishFunc publishes the event to the message broker.  It is called
+// again for the same event, if it returns an error, or if the relay fails
+// before the delivery is recorded.
+type PublishFunc func(ctx context.Context, e Event) error
+
+// Relay polls the outbox table and publishes the events.  Published events
+// are deleted from the table.
+//
+// On Postgres, the events are locked with FOR UPDATE SKIP LOCKED, so that
+// several relays can run concurrently without publishing the same event
+// twice.  On SQLite, only one relay should run.
+type Relay struct {
+	db        *sqlx.DB
+	publish   PublishFunc
+	batchSize uint64
+	interval  time.Duration
+}
+
+// RelayOption is a functional option for the [Relay].
+type RelayOption func(*Relay)
+
+// WithBatchSize sets the maximum number of events published in one
+// transaction.  The default is 100.
+func WithBatchSize(n int) RelayOption {
+	return func(r *Relay) {
+		if n > 0 {
+			r.batchSize = uint64(n)
+		}
+	}
+}
+
+// WithInterval sets the polling interval, used by [Relay.Run] when the
+// outbox is empty.  The default is 1 second.
+func WithInterval(d time.Duration) RelayOption {
+	return func(r *Relay) {
+		if d > 0 {
+			r.interval = d
+		}
+	}
+}
+
+// NewRelay returns the Relay, that publishes the events with publish.
+func NewRelay(db *sqlx.DB, publish PublishFunc, opts ...RelayOption) *Relay {
+	r := &Relay{db: db, publish: publish, batchSize: 100, interval: time.Second}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Run publishes the events until the context is cancelled.  The errors
+// are reported to onError, if it is not nil, and the events are retried on
+// the next poll.
+func (r *Relay) Run(ctx context.Context, onError func(error)) error {
+	for {
+		n, err := r.Process(ctx)
+		if err != nil && onError != nil && ctx.Err() == nil {
+			onError(err)
+		}
+		if n == int(r.batchSize) && err == nil {
+			// there may be more events.
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(r.interval):
+		}
+	}
+}
+
+// Process publishes one batch of events, and returns the number of
+// published events.  Publishing stops at the first error, the events
+// published before it are recorded as delivered.
+func (r *Relay) Process(ctx context.Context) (int, error) {
+	d := sqlhelp.DialectOf(r.db)
+	name, err := d.Identifier(Table)
+	if err != nil {
+		return 0, err
+	}
+	bld := sq.Select("id", "topic", "event_key", "payload", "created_at").
+		From(name).
+		OrderBy("id").
+		Limit(r.batchSize)
+	switch d {
+	case sqlhelp.DialectPostgres:
+		bld = bld.Suffix("FOR UPDATE SKIP LOCKED")
+	case sqlhelp.DialectSQLite:
+	default:
+		return 0, fmt.Errorf("%w: %s", sqlhelp.ErrUnsupportedDialect, r.db.DriverName())
+	}
+	stmt, args, err := bld.ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var events []Event
+	if err := tx.SelectContext(ctx, &events, tx.Rebind(stmt), args...); err != nil {
+		return 0, err
+	}
+	var (
+		ids    []int64
+		pubErr error
+	)
+	for _, e := range events {
+		if pubErr = r.publish(ctx, e); pubErr != nil {
+			pubErr = fmt.Errorf("publish event %d: %w", e.ID, pubErr)
+			break
+		}
+		ids = append(ids, e.ID)
+	}
+	if len(ids) > 0 {
+		stmt, args, err := sq.Delete(name).Where(sq.Eq{"id": ids}).ToSql()
+		if err != nil {
+			return 0, err
+		}
+		if _, err := tx.ExecContext(ctx, tx.Rebind(stmt), args...); err != nil {
+			return 0, err
+		}
+		if err := tx.Commit(); err != nil {
+			return 0, err
+		}
+	}
+	return len(ids), pubErr
+}
@@ -1,21 +1,403 @@
 package sqlhelp
 
 import (
+	"errors"
+	"fmt"
+	"maps"
+	"net"
 	"net/url"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 )
 
-// AddSearchPath adds a search path to postgres dsn string.
+// AddSearchPath adds a search path to postgres dsn string.  If the dsn
+// already has the search path, it is replaced.  If the dsn can't be parsed,
+// the search_path parameter is appended to it, as is.
 func AddSearchPath(dsn string, schema ...string) string {
-	searchPath := "search_path=" + strings.Join(schema, ",")
-	uri, err := url.Parse(dsn)
-	if err != nil || !strings.Contains(dsn, "://") {
-		// this accounts for "host=... user=... etc=..." conn string
-		return dsn + " " + searchPath
+	cfg, err := ParsePGConfig(dsn)
+	if err != nil {
+		return dsn + " search_path=" + strings.Join(schema, ",")
 	}
+	cfg.SetSearchPath(schema...)
+	return cfg.String()
+}
+
+// PGConfig is the Postgres connection configuration, that can be parsed
+// from, and rendered to, both URL ("postgres://user@host/db?sslmode=disable")
+// and key/value ("host=localhost user=user dbname=db") connection strings.
+//
+// Runtime parameters, such as search_path and statement_timeout, are passed
+// to the server in the "options" parameter, as "-c name=value".
+type PGConfig struct {
+	Host     string // host name, or the comma separated list of hosts
+	Port     string // port, or the comma separated list of ports
+	User     string
+	Password string
+	Database string
+	// Params are the other connection parameters, i.e. sslmode.
+	Params map[string]string
+	// Options are the runtime parameters sent in the "options" parameter.
+	Options map[string]string
+	// URL is true, if the config is rendered as URL by String.
+	URL bool
+	// Driver is the driver name, defaults to "postgres".
+	Driver string
+	Pool
+
+	rawOptions []string // options that are not runtime parameters
+}
+
+var _ Config = (*PGConfig)(nil)
+
+// NewPGConfig returns the config for the host and database, that is rendered
+// as URL.
+func NewPGConfig(host string, database string) *PGConfig {
+	return &PGConfig{
+		Host:     host,
+		Database: database,
+		Params:   make(map[string]string),
+		Options:  make(map[string]string),
+		URL:      true,
+	}
+}
+
+// ErrInvalidDSN is returned when the connection string can't be parsed.
+var ErrInvalidDSN = errors.New("invalid dsn")
+
+// ParsePGConfig parses the URL or key/value connection string.  A top-level
+// search_path parameter, as produced by earlier versions of AddSearchPath, is
+// moved to Options, unless the options already set it.
+func ParsePGConfig(dsn string) (*PGConfig, error) {
+	cfg := &PGConfig{Params: make(map[string]string), Options: make(map[string]string)}
+	var params map[string]string
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		var err error
+		if params, err = cfg.parseURL(dsn); err != nil {
+			return nil, err
+		}
+		cfg.URL = true
+	} else {
+		var err error
+		if params, err = parseKeyValue(dsn); err != nil {
+			return nil, err
+		}
+	}
+	for k, v := range params {
+		switch k {
+		case "host":
+			cfg.Host = v
+		case "port":
+			cfg.Port = v
+		case "user":
+			cfg.User = v
+		case "password":
+			cfg.Password = v
+		case "dbname":
+			cfg.Database = v
+		case "options":
+			cfg.parseOptions(v)
+		default:
+			cfg.Params[k] = v
+		}
+	}
+	if sp, ok := cfg.Params["search_path"]; ok {
+		if _, set := cfg.Options["search_path"]; !set {
+			cfg.SetOption("search_path", sp)
+		}
+		delete(cfg.Params, "search_path")
+	}
+	return cfg, nil
+}
+
+// parseURL parses the URL connection string, and returns its query
+// parameters.
+func (cfg *PGConfig) parseURL(dsn string) (map[string]string, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidDSN, err)
+	}
+	if u.User != nil {
+		cfg.User = u.User.Username()
+		cfg.Password, _ = u.User.Password()
+	}
+	var hosts, ports []string
+	for _, hp := range strings.Split(u.Host, ",") {
+		if hp == "" {
+			continue
+		}
+		host, port, err := net.SplitHostPort(hp)
+		if err != nil {
+			host, port = hp, ""
+		}
+		hosts = append(hosts, host)
+		ports = append(ports, port)
+	}
+	cfg.Host = strings.Join(hosts, ",")
+	if slices.ContainsFunc(ports, func(p string) bool { return p != "" }) {
+		cfg.Port = strings.Join(ports, ",")
+	}
+	cfg.Database = strings.TrimPrefix(u.Path, "/")
+
+	params := make(map[string]string)
+	for k, v := range u.Query() {
+		params[k] = v[len(v)-1]
+	}
+	return params, nil
+}
+
+// parseKeyValue parses the key/value connection string, where values may be
+// single-quoted, with backslash escapes.
+func parseKeyValue(dsn string) (map[string]string, error) {
+	params := make(map[string]string)
+	s := strings.TrimSpace(dsn)
+	for s != "" {
+		key, rest, found := strings.Cut(s, "=")
+		if !found {
+			return nil, fmt.Errorf("%w: missing \"=\" after %q", ErrInvalidDSN, s)
+		}
+		key = strings.TrimSpace(key)
+		if key == "" || strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("%w: invalid key %q", ErrInvalidDSN, key)
+		}
+		rest = strings.TrimLeft(rest, " \t")
+		var val strings.Builder
+		if strings.HasPrefix(rest, "'") {
+			i := 1
+			for ; i < len(rest) && rest[i] != '\''; i++ {
+				if rest[i] == '\\' && i+1 < len(rest) {
+					i++
+				}
+				val.WriteByte(rest[i])
+			}
+			if i >= len(rest) {
+				return nil, fmt.Errorf("%w: unterminated quote in %q", ErrInvalidDSN, key)
+			}
+			rest = rest[i+1:]
+		} else {
+			i := 0
+			for ; i < len(rest) && rest[i] != ' ' && rest[i] != '\t'; i++ {
+				if rest[i] == '\\' && i+1 < len(rest) {
+					i++
+				}
+				val.WriteByte(rest[i])
+			}
+			rest = rest[i:]
+		}
+		params[key] = val.String()
+		s = strings.TrimLeft(rest, " \t")
+	}
+	return params, nil
+}
+
+// parseOptions parses the "options" parameter.  Runtime parameters given as
+// "-c name=value" or "--name=value" are added to Options, the rest is
+// preserved as is.
+func (cfg *PGConfig) parseOptions(s string) {
+	args := splitOptions(s)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		var kv string
+		switch {
+		case arg == "-c" && i+1 < len(args):
+			i++
+			kv = args[i]
+		case strings.HasPrefix(arg, "-c") && len(arg) > 2:
+			kv = arg[2:]
+		case strings.HasPrefix(arg, "--"):
+			kv = arg[2:]
+		}
+		k, v, found := strings.Cut(kv, "=")
+		if !found {
+			cfg.rawOptions = append(cfg.rawOptions, arg)
+			continue
+		}
+		cfg.Options[strings.ReplaceAll(k, "-", "_")] = v
+	}
+}
+
+// splitOptions splits the options on the whitespace, honouring the
+// backslash escapes.
+func splitOptions(s string) []string {
+	var (
+		args []string
+		buf  strings.Builder
+	)
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && i+1 < len(s):
+			i++
+			buf.WriteByte(s[i])
+		case c == ' ' || c == '\t':
+			if buf.Len() > 0 {
+				args = append(args, buf.String())
+				buf.Reset()
+			}
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	if buf.Len() > 0 {
+		args = append(args, buf.String())
+	}
+	return args
+}
+
+// SetSearchPath sets the search_path runtime parameter.
+func (cfg *PGConfig) SetSearchPath(schema ...string) *PGConfig {
+	return cfg.SetOption("search_path", strings.Join(schema, ","))
+}
+
+// SetSSLMode sets the sslmode parameter, i.e. "disable" or "verify-full".
+func (cfg *PGConfig) SetSSLMode(mode string) *PGConfig {
+	return cfg.SetParam("sslmode", mode)
+}
+
+// SetApplicationName sets the application_name parameter.
+func (cfg *PGConfig) SetApplicationName(name string) *PGConfig {
+	return cfg.SetParam("application_name", name)
+}
 
-	vals := uri.Query()
-	vals.Add("options", "--"+searchPath)
-	uri.RawQuery = vals.Encode()
-	return uri.String()
+// SetStatementTimeout sets the statement_timeout runtime parameter, zero
+// disables the timeout.
+func (cfg *PGConfig) SetStatementTimeout(d time.Duration) *PGConfig {
+	return cfg.SetOption("statement_timeout", strconv.FormatInt(d.Milliseconds(), 10))
+}
+
+// SetConnectTimeout sets the connect_timeout parameter, it is rounded up to
+// whole seconds.
+func (cfg *PGConfig) SetConnectTimeout(d time.Duration) *PGConfig {
+	secs := int64((d + time.Second - 1) / time.Second)
+	return cfg.SetParam("connect_timeout", strconv.FormatInt(secs, 10))
+}
+
+// SetParam sets the connection parameter.  Empty value removes it.
+func (cfg *PGConfig) SetParam(name, value string) *PGConfig {
+	if cfg.Params == nil {
+		cfg.Params = make(map[string]string)
+	}
+	if value == "" {
+		delete(cfg.Params, name)
+	} else {
+		cfg.Params[name] = value
+	}
+	return cfg
+}
+
+// SetOption sets the runtime parameter.  Empty value removes it.
+func (cfg *PGConfig) SetOption(name, value string) *PGConfig {
+	if cfg.Options == nil {
+		cfg.Options = make(map[string]string)
+	}
+	if value == "" {
+		delete(cfg.Options, name)
+	} else {
+		cfg.Options[name] = value
+	}
+	return cfg
+}
+
+// options returns the rendered "options" parameter.
+func (cfg *PGConfig) options() string {
+	args := slices.Clone(cfg.rawOptions)
+	for _, k := range slices.Sorted(maps.Keys(cfg.Options)) {
+		args = append(args, "-c", k+"="+cfg.Options[k])
+	}
+	esc := strings.NewReplacer(`\`, `\\`, " ", `\ `)
+	for i, a := range args {
+		args[i] = esc.Replace(a)
+	}
+	return strings.Join(args, " ")
+}
+
+// params returns all parameters, except the host, port, user, password and
+// database.
+func (cfg *PGConfig) params() map[string]string {
+	params := maps.Clone(cfg.Params)
+	if params == nil {
+		params = make(map[string]string)
+	}
+	if opts := cfg.options(); opts != "" {
+		params["options"] = opts
+	}
+	return params
+}
+
+// DriverName returns the driver name.
+func (cfg *PGConfig) DriverName() string {
+	if cfg.Driver == "" {
+		return "postgres"
+	}
+	return cfg.Driver
+}
+
+// DSN returns the connection string, same as String.
+func (cfg *PGConfig) DSN() string {
+	return cfg.String()
+}
+
+// String returns the connection string in the form it was parsed from, see
+// URL field.
+func (cfg *PGConfig) String() string {
+	if cfg.URL {
+		return cfg.URLString()
+	}
+	return cfg.KeyValueString()
+}
+
+// URLString returns the URL connection string.
+func (cfg *PGConfig) URLString() string {
+	u := url.URL{Scheme: "postgres", Path: "/" + cfg.Database}
+	if cfg.User != "" || cfg.Password != "" {
+		if cfg.Password != "" {
+			u.User = url.UserPassword(cfg.User, cfg.Password)
+		} else {
+			u.User = url.User(cfg.User)
+		}
+	}
+	hosts := strings.Split(cfg.Host, ",")
+	ports := strings.Split(cfg.Port, ",")
+	for i, h := range hosts {
+		port := ports[min(i, len(ports)-1)]
+		if port != "" {
+			hosts[i] = net.JoinHostPort(h, port)
+		}
+	}
+	u.Host = strings.Join(hosts, ",")
+	vals := make(url.Values)
+	for k, v := range cfg.params() {
+		vals.Set(k, v)
+	}
+	u.RawQuery = vals.Encode()
+	return u.String()
+}
+
+// KeyValueString returns the key/value connection string.
+func (cfg *PGConfig) KeyValueString() string {
+	params := cfg.params()
+	for k, v := range map[string]string{
+		"host":     cfg.Host,
+		"port":     cfg.Port,
+		"user":     cfg.User,
+		"password": cfg.Password,
+		"dbname":   cfg.Database,
+	} {
+		if v != "" {
+			params[k] = v
+		}
+	}
+	pairs := make([]string, 0, len(params))
+	for _, k := range slices.Sorted(maps.Keys(params)) {
+		pairs = append(pairs, k+"="+quoteValue(params[k]))
+	}
+	return strings.Join(pairs, " ")
+}
+
+// quoteValue quotes the key/value connection string value, if necessary.
+func quoteValue(v string) string {
+	if v != "" && !strings.ContainsAny(v, " \t'\\") {
+		return v
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v) + "'"
 }
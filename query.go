@@ -0,0 +1,70 @@
+package sqlhelp
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"iter"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// In this file: helpers for raw SQL queries.  Queries use the "?"
+// placeholders, which are rebound to the database flavour automatically.
+
+// ErrNotFound is returned by [QueryOne] when the query returns no rows.
+var ErrNotFound = errors.New("not found")
+
+// Query runs the raw SQL query and returns the iterator over the results.
+func Query[T any](ctx context.Context, db sqlx.ExtContext, query string, args ...any) (iter.Seq2[T, error], error) {
+	rows, err := db.QueryxContext(ctx, db.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	return rowsIter[T](ctx, rows), nil
+}
+
+// QueryNamed runs the raw SQL query with named parameters, i.e. ":name",
+// taking values from the argument struct or map, and returns the iterator
+// over the results.
+func QueryNamed[T any](ctx context.Context, db sqlx.ExtContext, query string, arg any) (iter.Seq2[T, error], error) {
+	q, args, err := db.BindNamed(query, arg)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.QueryxContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	return rowsIter[T](ctx, rows), nil
+}
+
+// QueryOne runs the raw SQL query and returns the first row.  If the query
+// returns no rows, it returns [ErrNotFound].
+func QueryOne[T any](ctx context.Context, db sqlx.ExtContext, query string, args ...any) (*T, error) {
+	var res T
+	if err := scanRow(db.QueryRowxContext(ctx, db.Rebind(query), args...), &res); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if err := afterSelect(ctx, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// QueryScalar runs the raw SQL query that returns a single value, i.e. a
+// count, and scans it into V.  If the query returns no rows, it returns
+// [ErrNotFound].
+func QueryScalar[V any](ctx context.Context, db sqlx.ExtContext, query string, args ...any) (V, error) {
+	var v V
+	if err := db.QueryRowxContext(ctx, db.Rebind(query), args...).Scan(&v); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return v, ErrNotFound
+		}
+		return v, err
+	}
+	return v, nil
+}
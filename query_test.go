@@ -0,0 +1,181 @@
+package sqlhelp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/rusq/sqlhelp/sqlhelptest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuery(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		db, mock := sqlhelptest.InitMockDB(t)
+		mock.ExpectQuery(`SELECT \* FROM test_table WHERE id > \$1 AND name = \$2`).
+			WithArgs(0, "test").
+			WillReturnRows(sqlmock.NewRows(testStructCols).AddRow(testStructBinds...).AddRow(testStructBinds...)).
+			RowsWillBeClosed()
+		it, err := Query[TestStruct](context.Background(), db, "SELECT * FROM test_table WHERE id > ? AND name = ?", 0, "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := Collect2(it)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, []TestStruct{filledStruct, filledStruct}, got)
+	})
+	t.Run("query error", func(t *testing.T) {
+		db, mock := sqlhelptest.InitMockDB(t)
+		mock.ExpectQuery(`SELECT`).WillReturnError(assert.AnError)
+		_, err := Query[TestStruct](context.Background(), db, "SELECT * FROM test_table")
+		assert.ErrorIs(t, err, assert.AnError)
+	})
+}
+
+func TestQueryNamed(t *testing.T) {
+	type args struct {
+		ID   int    `db:"id"`
+		Name string `db:"name"`
+	}
+	t.Run("struct", func(t *testing.T) {
+		db, mock := sqlhelptest.InitMockDB(t)
+		mock.ExpectQuery(`SELECT \* FROM test_table WHERE id = \$1 AND name = \$2`).
+			WithArgs(1, "test").
+			WillReturnRows(sqlmock.NewRows(testStructCols).AddRow(testStructBinds...))
+		it, err := QueryNamed[TestStruct](context.Background(), db, "SELECT * FROM test_table WHERE id = :id AND name = :name", args{ID: 1, Name: "test"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := Collect2(it)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, []TestStruct{filledStruct}, got)
+	})
+	t.Run("map", func(t *testing.T) {
+		db, mock := sqlhelptest.InitMockDB(t)
+		mock.ExpectQuery(`SELECT \* FROM test_table WHERE id = \$1`).
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(testStructCols).AddRow(testStructBinds...))
+		it, err := QueryNamed[TestStruct](context.Background(), db, "SELECT * FROM test_table WHERE id = :id", map[string]any{"id": 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := Collect2(it)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, []TestStruct{filledStruct}, got)
+	})
+	t.Run("missing parameter", func(t *testing.T) {
+		db, _ := sqlhelptest.InitMockDB(t)
+		_, err := QueryNamed[TestStruct](context.Background(), db, "SELECT * FROM test_table WHERE id = :missing", args{})
+		assert.Error(t, err)
+	})
+}
+
+func TestQueryOne(t *testing.T) {
+	tests := []struct {
+		name     string
+		expectFn sqlhelptest.ExpectFunc
+		want     *TestStruct
+		wantErr  error
+	}{
+		{
+			"ok",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`SELECT \* FROM test_table WHERE id = \$1`).
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows(testStructCols).AddRow(testStructBinds...))
+			},
+			&filledStruct,
+			nil,
+		},
+		{
+			"not found",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`SELECT \* FROM test_table WHERE id = \$1`).
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows(testStructCols))
+			},
+			nil,
+			ErrNotFound,
+		},
+		{
+			"query error",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`SELECT \* FROM test_table WHERE id = \$1`).
+					WithArgs(1).
+					WillReturnError(assert.AnError)
+			},
+			nil,
+			assert.AnError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := sqlhelptest.InitMockDB(t)
+			tt.expectFn(mock)
+			got, err := QueryOne[TestStruct](context.Background(), db, "SELECT * FROM test_table WHERE id = ?", 1)
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestQuery_scalar(t *testing.T) {
+	ctx := context.Background()
+	db := initRelDB(t)
+
+	ids, err := Query[int64](ctx, db, "SELECT id FROM posts WHERE user_id = ? ORDER BY id", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Collect2(ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []int64{1, 2}, got)
+
+	name, err := QueryOne[string](ctx, db, "SELECT name FROM users WHERE id = ?", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "bob", *name)
+}
+
+func TestQueryScalar(t *testing.T) {
+	ctx := context.Background()
+	db := initRelDB(t)
+
+	t.Run("count", func(t *testing.T) {
+		got, err := QueryScalar[int64](ctx, db, "SELECT COUNT(*) FROM posts WHERE user_id = ?", 1)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), got)
+	})
+	t.Run("string", func(t *testing.T) {
+		got, err := QueryScalar[string](ctx, db, "SELECT title FROM posts WHERE id = ?", 3)
+		assert.NoError(t, err)
+		assert.Equal(t, "third", got)
+	})
+	t.Run("bytes", func(t *testing.T) {
+		got, err := QueryScalar[[]byte](ctx, db, "SELECT CAST(title AS BLOB) FROM posts WHERE id = ?", 3)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("third"), got)
+	})
+	t.Run("time", func(t *testing.T) {
+		db, mock := sqlhelptest.InitMockDB(t)
+		mock.ExpectQuery(`SELECT MAX\(created_at\) FROM test_table`).
+			WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(testDate))
+		got, err := QueryScalar[time.Time](ctx, db, "SELECT MAX(created_at) FROM test_table")
+		assert.NoError(t, err)
+		assert.Equal(t, testDate, got)
+	})
+	t.Run("not found", func(t *testing.T) {
+		_, err := QueryScalar[int64](ctx, db, "SELECT id FROM posts WHERE id = ?", 42)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
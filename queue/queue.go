@@ -0,0 +1,305 @@
+// Package queue implements the job queue backed by the database table.
+//
+// Jobs are dequeued in batches, and stay invisible to other consumers for
+// the visibility timeout.  A consumer must acknowledge the processed jobs
+// with [Queue.Ack], or return them to the queue with [Queue.Nack].  Jobs
+// that are neither acknowledged nor returned, i.e. because the consumer
+// crashed, are redelivered after the visibility timeout, so the jobs are
+// processed at least once.
+//
+// On Postgres, several consumers can dequeue concurrently, the locked rows
+// are skipped with FOR UPDATE SKIP LOCKED.  On SQLite, the dequeue runs in
+// the BEGIN IMMEDIATE transaction, that serialises the consumers.
+package queue
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+	"github.com/rusq/sqlhelp"
+)
+
+// Job is the dequeued job.
+type Job[T any] struct {
+	ID       int64
+	Payload  T
+	Attempts int // number of deliveries, including the current one
+}
+
+// row is the row of the queue table.  Timestamps are stored as Unix
+// milliseconds, so that they compare correctly on all databases.
+type row[T any] struct {
+	ID        int64   `db:"id,pk"`
+	Payload   T       `db:"payload,json"`
+	Attempts  int     `db:"attempts,notnull"`
+	VisibleAt int64   `db:"visible_at,notnull"`
+	FailedAt  *int64  `db:"failed_at"`
+	LastError *string `db:"last_error"`
+}
+
+// Queue is the queue of the jobs with the payload of type T.  The payload
+// is stored as JSON.
+type Queue[T any] struct {
+	db    *sqlx.DB
+	table string // quoted
+	raw   string // as given
+	options
+}
+
+type options struct {
+	visibility  time.Duration
+	maxAttempts int
+	backoff     func(attempt int) time.Duration
+	now         func() time.Time
+}
+
+// Option is a functional option for the [Queue].
+type Option func(*options)
+
+// WithVisibilityTimeout sets the time the dequeued job stays invisible to
+// other consumers.  The default is 30 seconds.
+func WithVisibilityTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.visibility = d
+	}
+}
+
+// WithMaxAttempts sets the number of deliveries, after which the job that
+// is returned with [Queue.Nack] is marked as failed, and is not delivered
+// anymore.  Zero means no limit.  The default is 5.
+func WithMaxAttempts(n int) Option {
+	return func(o *options) {
+		o.maxAttempts = n
+	}
+}
+
+// WithBackoff sets the function that returns the delay before the job that
+// is returned with [Queue.Nack] after the attempt is delivered again.  The
+// default is the exponential backoff starting at 1 second, up to 1 hour.
+func WithBackoff(fn func(attempt int) time.Duration) Option {
+	return func(o *options) {
+		o.backoff = fn
+	}
+}
+
+// DefaultBackoff is the default backoff, see [WithBackoff].
+func DefaultBackoff(attempt int) time.Duration {
+	if attempt > 12 {
+		return time.Hour
+	}
+	return min(time.Second<<max(attempt-1, 0), time.Hour)
+}
+
+// New returns the queue backed by the table.  Create the table with the
+// statement returned by [Queue.CreateTableSQL].
+func New[T any](db *sqlx.DB, table string, opts ...Option) (*Queue[T], error) {
+	d := sqlhelp.DialectOf(db)
+	switch d {
+	case sqlhelp.DialectPostgres, sqlhelp.DialectSQLite:
+	default:
+		return nil, fmt.Errorf("%w: %s", sqlhelp.ErrUnsupportedDialect, db.DriverName())
+	}
+	name, err := d.Identifier(table)
+	if err != nil {
+		return nil, err
+	}
+	q := &Queue[T]{
+		db:    db,
+		table: name,
+		raw:   table,
+		options: options{
+			visibility:  30 * time.Second,
+			maxAttempts: 5,
+			backoff:     DefaultBackoff,
+			now:         time.Now,
+		},
+	}
+	for _, opt := range opts {
+		opt(&q.options)
+	}
+	return q, nil
+}
+
+// CreateTableSQL returns the CREATE TABLE statement for the queue table.
+func (q *Queue[T]) CreateTableSQL() (string, error) {
+	return sqlhelp.CreateTableSQL[row[T]](sqlhelp.DialectOf(q.db), q.raw)
+}
+
+// ms returns the Unix milliseconds of the time t.
+func ms(t time.Time) int64 {
+	return t.UnixMilli()
+}
+
+// Enqueue adds the jobs with the payloads to the queue.  If the context
+// carries the transaction (see [sqlhelp.ContextWithTx]), the jobs are added
+// within it.
+func (q *Queue[T]) Enqueue(ctx context.Context, payloads ...T) error {
+	if len(payloads) == 0 {
+		return nil
+	}
+	var db sqlx.ExtContext = q.db
+	if tx, ok := sqlhelp.TxFromContext(ctx); ok {
+		db = tx
+	}
+	now := ms(q.now())
+	bld := sq.Insert(q.table).Columns("payload", "attempts", "visible_at")
+	for _, p := range payloads {
+		bld = bld.Values(sqlhelp.JSONValue(p), 0, now)
+	}
+	stmt, args, err := bld.ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, db.Rebind(stmt), args...)
+	return err
+}
+
+// Dequeue returns up to n visible jobs, in the order they were enqueued,
+// and hides them for the visibility timeout.  It returns an empty slice if
+// there are no visible jobs.
+func (q *Queue[T]) Dequeue(ctx context.Context, n int) ([]Job[T], error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	now := q.now()
+	bld := sq.Select("id", "payload", "attempts").
+		From(q.table).
+		Where(sq.Eq{"failed_at": nil}).
+		Where(sq.LtOrEq{"visible_at": ms(now)}).
+		OrderBy("id").
+		Limit(uint64(n))
+	if sqlhelp.DialectOf(q.db) != sqlhelp.DialectSQLite {
+		bld = bld.Suffix("FOR UPDATE SKIP LOCKED")
+	}
+	stmt, args, err := bld.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	var jobs []Job[T]
+	err = q.inTx(ctx, func(db sqlx.ExtContext) error {
+		it, err := sqlhelp.Query[row[T]](ctx, db, stmt, args...)
+		if err != nil {
+			return err
+		}
+		rows, err := sqlhelp.Collect2(it)
+		if err != nil || len(rows) == 0 {
+			return err
+		}
+		ids := make([]int64, len(rows))
+		for i, r := range rows {
+			ids[i] = r.ID
+			jobs = append(jobs, Job[T]{ID: r.ID, Payload: r.Payload, Attempts: r.Attempts + 1})
+		}
+		stmt, args, err := sq.Update(q.table).
+			Set("attempts", sq.Expr("attempts + 1")).
+			Set("visible_at", ms(now.Add(q.visibility))).
+			Where(sq.Eq{"id": ids}).
+			ToSql()
+		if err != nil {
+			return err
+		}
+		_, err = db.ExecContext(ctx, db.Rebind(stmt), args...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// Ack removes the processed jobs from the queue.
+func (q *Queue[T]) Ack(ctx context.Context, ids ...int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	stmt, args, err := sq.Delete(q.table).Where(sq.Eq{"id": ids}).ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = q.db.ExecContext(ctx, q.db.Rebind(stmt), args...)
+	return err
+}
+
+// Nack returns the job to the queue, to be delivered again after the
+// backoff delay, see [WithBackoff].  If the job has reached the maximum
+// number of attempts, it is marked as failed instead.  The cause, if not
+// nil, is recorded as the last error of the job.
+func (q *Queue[T]) Nack(ctx context.Context, job Job[T], cause error) error {
+	now := q.now()
+	bld := sq.Update(q.table).Where(sq.Eq{"id": job.ID})
+	if q.maxAttempts > 0 && job.Attempts >= q.maxAttempts {
+		bld = bld.Set("failed_at", ms(now))
+	} else {
+		bld = bld.Set("visible_at", ms(now.Add(q.backoff(job.Attempts))))
+	}
+	var lastErr *string
+	if cause != nil {
+		s := cause.Error()
+		lastErr = &s
+	}
+	stmt, args, err := bld.Set("last_error", lastErr).ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = q.db.ExecContext(ctx, q.db.Rebind(stmt), args...)
+	return err
+}
+
+// inTx runs fn in the transaction.  On SQLite, the transaction is started
+// with BEGIN IMMEDIATE, that takes the write lock upfront.
+func (q *Queue[T]) inTx(ctx context.Context, fn func(db sqlx.ExtContext) error) error {
+	if sqlhelp.DialectOf(q.db) != sqlhelp.DialectSQLite {
+		return sqlhelp.InTx(ctx, q.db, func(ctx context.Context) error {
+			tx, _ := sqlhelp.TxFromContext(ctx)
+			return fn(tx)
+		})
+	}
+	conn, err := q.db.Connx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "BEGIN IMMEDIATE"); err != nil {
+		return err
+	}
+	// the transaction must end even if ctx is cancelled, otherwise the
+	// connection goes back to the pool holding the write lock.
+	if err := fn(sqliteConn{conn, q.db.DriverName()}); err != nil {
+		_, rbErr := conn.ExecContext(context.WithoutCancel(ctx), "ROLLBACK")
+		if rbErr != nil {
+			discard(conn)
+		}
+		return errors.Join(err, rbErr)
+	}
+	if _, err := conn.ExecContext(context.WithoutCancel(ctx), "COMMIT"); err != nil {
+		discard(conn)
+		return err
+	}
+	return nil
+}
+
+// discard closes the connection, that may be left in the transaction,
+// instead of returning it to the pool.
+func discard(conn *sqlx.Conn) {
+	conn.Raw(func(any) error { return driver.ErrBadConn })
+}
+
+// sqliteConn is the [sqlx.Conn] that satisfies [sqlx.ExtContext].
+type sqliteConn struct {
+	*sqlx.Conn
+	driverName string
+}
+
+func (c sqliteConn) DriverName() string {
+	return c.driverName
+}
+
+func (c sqliteConn) BindNamed(query string, arg any) (string, []any, error) {
+	return sqlx.BindNamed(sqlx.BindType(c.driverName), query, arg)
+}
@@ -0,0 +1,199 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/rusq/sqlhelp"
+	"github.com/rusq/sqlhelp/sqlhelptest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+)
+
+type email struct {
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+}
+
+// clock is the fake clock for the tests.
+type clock struct{ t time.Time }
+
+func newClock() *clock {
+	return &clock{time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *clock) now() time.Time { return c.t }
+
+func (c *clock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func ids[T any](jobs []Job[T]) []int64 {
+	var res []int64
+	for _, j := range jobs {
+		res = append(res, j.ID)
+	}
+	return res
+}
+
+func initQueue(t *testing.T, opts ...Option) (*Queue[email], *clock) {
+	t.Helper()
+	db := sqlhelptest.InitSqliteDB(t)
+	q, err := New[email](db, "email_jobs", opts...)
+	require.NoError(t, err)
+	c := newClock()
+	q.now = c.now
+	stmt, err := q.CreateTableSQL()
+	require.NoError(t, err)
+	db.MustExec(stmt)
+	return q, c
+}
+
+func TestQueue(t *testing.T) {
+	ctx := context.Background()
+	q, c := initQueue(t, WithVisibilityTimeout(time.Minute), WithMaxAttempts(2), WithBackoff(func(int) time.Duration { return 10 * time.Second }))
+
+	require.NoError(t, q.Enqueue(ctx, email{"a@example.com", "1"}, email{"b@example.com", "2"}, email{"c@example.com", "3"}))
+
+	jobs, err := q.Dequeue(ctx, 2)
+	require.NoError(t, err)
+	require.Len(t, jobs, 2)
+	assert.Equal(t, []int64{1, 2}, ids(jobs))
+	assert.Equal(t, email{"a@example.com", "1"}, jobs[0].Payload)
+	assert.Equal(t, 1, jobs[0].Attempts)
+
+	// dequeued jobs are invisible.
+	more, err := q.Dequeue(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{3}, ids(more))
+
+	require.NoError(t, q.Ack(ctx, jobs[0].ID, more[0].ID))
+	require.NoError(t, q.Nack(ctx, jobs[1], errors.New("smtp timeout")))
+
+	// the nacked job is redelivered after the backoff.
+	c.advance(5 * time.Second)
+	empty, err := q.Dequeue(ctx, 10)
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+	c.advance(5 * time.Second)
+	retry, err := q.Dequeue(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, retry, 1)
+	assert.Equal(t, jobs[1].ID, retry[0].ID)
+	assert.Equal(t, 2, retry[0].Attempts)
+
+	// max attempts reached, the job fails.
+	require.NoError(t, q.Nack(ctx, retry[0], errors.New("smtp timeout")))
+	c.advance(time.Hour)
+	empty, err = q.Dequeue(ctx, 10)
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+	var lastErr string
+	require.NoError(t, q.db.Get(&lastErr, "SELECT last_error FROM email_jobs WHERE failed_at IS NOT NULL"))
+	assert.Equal(t, "smtp timeout", lastErr)
+}
+
+func TestQueue_visibilityTimeout(t *testing.T) {
+	ctx := context.Background()
+	q, c := initQueue(t, WithVisibilityTimeout(time.Minute))
+	require.NoError(t, q.Enqueue(ctx, email{To: "a@example.com"}))
+
+	jobs, err := q.Dequeue(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+
+	// the consumer crashed, the job is redelivered after the timeout.
+	c.advance(59 * time.Second)
+	jobs, err = q.Dequeue(ctx, 1)
+	require.NoError(t, err)
+	assert.Empty(t, jobs)
+	c.advance(time.Second)
+	jobs, err = q.Dequeue(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, 2, jobs[0].Attempts)
+}
+
+func TestQueue_Enqueue_tx(t *testing.T) {
+	ctx := context.Background()
+	q, _ := initQueue(t)
+
+	err := sqlhelp.InTx(ctx, q.db, func(ctx context.Context) error {
+		if err := q.Enqueue(ctx, email{To: "a@example.com"}); err != nil {
+			return err
+		}
+		return errors.New("rollback")
+	})
+	require.Error(t, err)
+	jobs, err := q.Dequeue(ctx, 1)
+	require.NoError(t, err)
+	assert.Empty(t, jobs)
+}
+
+func TestQueue_Dequeue_postgres(t *testing.T) {
+	db, mock := sqlhelptest.InitMockDB(t)
+	q, err := New[email](db, "email_jobs", WithVisibilityTimeout(time.Minute))
+	require.NoError(t, err)
+	c := newClock()
+	q.now = c.now
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, payload, attempts FROM email_jobs WHERE failed_at IS NULL AND visible_at <= \$1 ORDER BY id LIMIT 10 FOR UPDATE SKIP LOCKED`).
+		WithArgs(c.t.UnixMilli()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "payload", "attempts"}).AddRow(7, `{"to":"a@example.com"}`, 0))
+	mock.ExpectExec(`UPDATE email_jobs SET attempts = attempts \+ 1, visible_at = \$1 WHERE id IN \(\$2\)`).
+		WithArgs(c.t.Add(time.Minute).UnixMilli(), 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	jobs, err := q.Dequeue(context.Background(), 10)
+	require.NoError(t, err)
+	assert.Equal(t, []Job[email]{{ID: 7, Payload: email{To: "a@example.com"}, Attempts: 1}}, jobs)
+}
+
+func TestNew(t *testing.T) {
+	db, _, _ := sqlmock.New()
+	defer db.Close()
+	for _, driver := range []string{"unknown", "mysql"} {
+		_, err := New[email](sqlx.NewDb(db, driver), "jobs")
+		assert.ErrorIs(t, err, sqlhelp.ErrUnsupportedDialect, driver)
+	}
+	_, err := New[email](sqlx.NewDb(db, "postgres"), "jobs; DROP TABLE users")
+	assert.ErrorIs(t, err, sqlhelp.ErrInvalidIdent)
+}
+
+func TestDefaultBackoff(t *testing.T) {
+	assert.Equal(t, time.Second, DefaultBackoff(1))
+	assert.Equal(t, 4*time.Second, DefaultBackoff(3))
+	assert.Equal(t, time.Hour, DefaultBackoff(100))
+}
+
+func TestQueue_inTx(t *testing.T) {
+	t.Run("commit after cancel", func(t *testing.T) {
+		q, _ := initQueue(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		err := q.inTx(ctx, func(db sqlx.ExtContext) error {
+			_, err := db.ExecContext(context.Background(), "INSERT INTO email_jobs (payload, attempts, visible_at) VALUES ('{}', 0, 0)")
+			cancel()
+			return err
+		})
+		require.NoError(t, err)
+		var n int
+		require.NoError(t, q.db.Get(&n, "SELECT COUNT(*) FROM email_jobs"))
+		assert.Equal(t, 1, n)
+		// the write lock is released.
+		require.NoError(t, q.Enqueue(context.Background(), email{To: "a@example.com"}))
+	})
+	t.Run("failed commit discards the connection", func(t *testing.T) {
+		q, _ := initQueue(t)
+		err := q.inTx(context.Background(), func(db sqlx.ExtContext) error {
+			_, err := db.ExecContext(context.Background(), "ROLLBACK")
+			return err
+		})
+		assert.Error(t, err)
+		assert.Zero(t, q.db.Stats().OpenConnections)
+	})
+}
@@ -0,0 +1,314 @@
+package sqlhelp
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"sync"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+)
+
+// RelationKind is the kind of the relationship between two struct types.
+type RelationKind int
+
+const (
+	// HasOne relation: the related table has a foreign key referencing the
+	// parent, and there's at most one related row.
+	HasOne RelationKind = iota
+	// HasMany relation: the related table has a foreign key referencing the
+	// parent.
+	HasMany
+	// BelongsTo relation: the parent table has a foreign key referencing the
+	// related table.
+	BelongsTo
+)
+
+func (k RelationKind) String() string {
+	switch k {
+	case HasOne:
+		return "has-one"
+	case HasMany:
+		return "has-many"
+	case BelongsTo:
+		return "belongs-to"
+	default:
+		return fmt.Sprintf("RelationKind(%d)", int(k))
+	}
+}
+
+// Relation describes the relationship between the parent struct and the
+// related struct held in the parent's Field.
+type Relation struct {
+	Kind RelationKind
+	// Field is the name of the parent struct field that receives related
+	// rows.  It must be a slice of structs (or pointers to structs) for
+	// HasMany, and a struct or a pointer to struct otherwise.  The field
+	// must be tagged `db:"-"`, so that it's not mistaken for a column.
+	Field string
+	// Table is the table of the related rows.
+	Table string
+	// ForeignKey is the column referencing the other table: the column of
+	// the related table for HasOne and HasMany, and the column of the parent
+	// table for BelongsTo.
+	ForeignKey string
+	// References is the column referenced by the ForeignKey, "id" if empty.
+	References string
+}
+
+// relations holds the declared relations, map[reflect.Type]map[string]Relation.
+var relations sync.Map
+
+// Relate declares relations of the struct type T.  It is intended to be
+// called on initialisation, and panics if the relation is invalid.
+//
+//	func init() {
+//		sqlhelp.Relate[User](
+//			sqlhelp.Relation{Kind: sqlhelp.HasMany, Field: "Posts", Table: "posts", ForeignKey: "user_id"},
+//		)
+//	}
+func Relate[T any](rels ...Relation) {
+	typ := reflect.TypeFor[T]()
+	m := make(map[string]Relation)
+	if existing, ok := relations.Load(typ); ok {
+		for name, rel := range existing.(map[string]Relation) {
+			m[name] = rel
+		}
+	}
+	for _, rel := range rels {
+		if err := rel.validate(typ); err != nil {
+			panic(fmt.Sprintf("sqlhelp: Relate[%s]: %s", typ, err))
+		}
+		if rel.References == "" {
+			rel.References = "id"
+		}
+		m[rel.Field] = rel
+	}
+	relations.Store(typ, m)
+}
+
+// validate checks that the relation can be loaded into the struct type typ.
+func (rel Relation) validate(typ reflect.Type) error {
+	if rel.Table == "" || rel.ForeignKey == "" {
+		return fmt.Errorf("%s: table and foreign key are required", rel.Field)
+	}
+	for _, name := range []string{rel.Table, rel.ForeignKey, rel.References} {
+		if name == "" {
+			continue
+		}
+		if err := ValidateIdent(name); err != nil {
+			return fmt.Errorf("%s: %w", rel.Field, err)
+		}
+	}
+	sf, ok := typ.FieldByName(rel.Field)
+	if !ok {
+		return fmt.Errorf("%s: no such field", rel.Field)
+	}
+	ft := sf.Type
+	if rel.Kind == HasMany {
+		if ft.Kind() != reflect.Slice {
+			return fmt.Errorf("%s: %s relation requires a slice field", rel.Field, rel.Kind)
+		}
+		ft = ft.Elem()
+	}
+	if ft.Kind() == reflect.Pointer {
+		ft = ft.Elem()
+	}
+	if ft.Kind() != reflect.Struct {
+		return fmt.Errorf("%s: unsupported field type %s", rel.Field, sf.Type)
+	}
+	if name, _ := parseTag(sf.Tag.Get(Tag)); name != "-" {
+		return fmt.Errorf("%s: relation field must be tagged `%s:\"-\"`", rel.Field, Tag)
+	}
+	return nil
+}
+
+// relationOf returns the relation declared for the field of the type typ.
+func relationOf(typ reflect.Type, field string) (Relation, error) {
+	if m, ok := relations.Load(typ); ok {
+		if rel, ok := m.(map[string]Relation)[field]; ok {
+			return rel, nil
+		}
+	}
+	return Relation{}, fmt.Errorf("no relation declared for %s.%s", typ, field)
+}
+
+// SelectOption is a functional option for [Select] and [SelectRow].
+type SelectOption func(*selectOptions)
+
+type selectOptions struct {
+	preload []string
+	column  string
+}
+
+func newSelectOptions(opts []SelectOption) selectOptions {
+	var o selectOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// Preload returns a [SelectOption] that loads the related rows into the
+// fields, which must be declared with [Relate].  Each relation is loaded
+// with one extra query for all selected rows.
+func Preload(fields ...string) SelectOption {
+	return func(o *selectOptions) {
+		o.preload = append(o.preload, fields...)
+	}
+}
+
+// ScalarColumn returns a [SelectOption] that selects the single column, for
+// [Select] with the scalar type, such as int64 or string, i.e.:
+//
+//	ids, err := Select[int64](ctx, db, "users", where, ScalarColumn("id"))
+func ScalarColumn(name string) SelectOption {
+	return func(o *selectOptions) {
+		o.column = name
+	}
+}
+
+// preload loads related rows for each of the fields into the parents, which
+// must be a slice of structs.
+func preload(ctx context.Context, db sqlx.ExtContext, parents reflect.Value, fields []string) error {
+	typ := parents.Type().Elem()
+	for _, name := range fields {
+		rel, err := relationOf(typ, name)
+		if err != nil {
+			return err
+		}
+		if err := rel.load(ctx, db, parents); err != nil {
+			return fmt.Errorf("preload %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// load loads related rows into the parents.
+func (rel Relation) load(ctx context.Context, db sqlx.ExtContext, parents reflect.Value) error {
+	parentKey, relatedKey := rel.References, rel.ForeignKey
+	if rel.Kind == BelongsTo {
+		parentKey, relatedKey = rel.ForeignKey, rel.References
+	}
+
+	// collect parent keys.
+	var (
+		keys  []any
+		seen  = make(map[string]bool)
+		pkeys = make([]*string, parents.Len())
+	)
+	for i := range parents.Len() {
+		val, ok := columnValue(parents.Index(i), parentKey)
+		if !ok {
+			continue
+		}
+		k := fmt.Sprint(val)
+		pkeys[i] = &k
+		if !seen[k] {
+			seen[k] = true
+			keys = append(keys, val)
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	// fetch related rows, grouped by the key.
+	fld, _ := parents.Type().Elem().FieldByName(rel.Field)
+	elemType := fld.Type
+	if rel.Kind == HasMany {
+		elemType = elemType.Elem()
+	}
+	structType := elemType
+	if structType.Kind() == reflect.Pointer {
+		structType = structType.Elem()
+	}
+	quotedKey, err := DialectOf(db).Identifier(relatedKey)
+	if err != nil {
+		return err
+	}
+	bld, err := selectBuilder(ctx, db, rel.Table, sq.Eq{quotedKey: keys}, columnsOf(structType))
+	if err != nil {
+		return err
+	}
+	query, args, err := bld.ToSql()
+	if err != nil {
+		return err
+	}
+	rows, err := db.QueryxContext(ctx, db.Rebind(query), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	related := make(map[string][]reflect.Value)
+	for rows.Next() {
+		v := reflect.New(structType)
+		if err := scanStruct(rows, v.Interface()); err != nil {
+			return err
+		}
+		if h, ok := v.Interface().(AfterSelecter); ok {
+			if err := h.AfterSelect(ctx); err != nil {
+				return fmt.Errorf("AfterSelect: %w", err)
+			}
+		}
+		val, ok := columnValue(v.Elem(), relatedKey)
+		if !ok {
+			continue
+		}
+		k := fmt.Sprint(val)
+		if elemType.Kind() != reflect.Pointer {
+			v = v.Elem()
+		}
+		related[k] = append(related[k], v)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// assign related rows to parents.
+	for i, k := range pkeys {
+		if k == nil {
+			continue
+		}
+		rr, ok := related[*k]
+		if !ok {
+			continue
+		}
+		dst := parents.Index(i).FieldByIndex(fld.Index)
+		if rel.Kind == HasMany {
+			dst.Set(reflect.Append(reflect.MakeSlice(dst.Type(), 0, len(rr)), rr...))
+		} else {
+			dst.Set(rr[0])
+		}
+	}
+	return nil
+}
+
+// columnValue returns the value of the column of the struct v.  It returns
+// false if there is no such column or the value is NULL.
+func columnValue(v reflect.Value, column string) (any, bool) {
+	for _, f := range fieldsOf(v.Type()) {
+		if f.column != column {
+			continue
+		}
+		val := v.FieldByIndex(f.index).Interface()
+		if vlr, ok := val.(driver.Valuer); ok {
+			dv, err := vlr.Value()
+			if err != nil {
+				return nil, false
+			}
+			val = dv
+		}
+		rv := reflect.ValueOf(val)
+		for rv.IsValid() && rv.Kind() == reflect.Pointer {
+			rv = rv.Elem()
+		}
+		if !rv.IsValid() {
+			return nil, false
+		}
+		return rv.Interface(), true
+	}
+	return nil, false
+}
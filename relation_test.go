@@ -0,0 +1,163 @@
+package sqlhelp
+
+import (
+	"context"
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+	"github.com/rusq/sqlhelp/sqlhelptest"
+	"github.com/stretchr/testify/assert"
+)
+
+type relUser struct {
+	ID      int64       `db:"id"`
+	Name    string      `db:"name"`
+	Posts   []relPost   `db:"-"`
+	Profile *relProfile `db:"-"`
+}
+
+type relPost struct {
+	ID     int64    `db:"id"`
+	UserID int64    `db:"user_id"`
+	Title  string   `db:"title"`
+	Author *relUser `db:"-"`
+}
+
+type relProfile struct {
+	UserID int64  `db:"user_id"`
+	Bio    string `db:"bio"`
+}
+
+func init() {
+	Relate[relUser](
+		Relation{Kind: HasMany, Field: "Posts", Table: "posts", ForeignKey: "user_id"},
+		Relation{Kind: HasOne, Field: "Profile", Table: "profiles", ForeignKey: "user_id"},
+	)
+	Relate[relPost](
+		Relation{Kind: BelongsTo, Field: "Author", Table: "users", ForeignKey: "user_id"},
+	)
+}
+
+func initRelDB(t *testing.T) *sqlx.DB {
+	t.Helper()
+	db := sqlhelptest.InitSqliteDB(t)
+	var stmt = []string{
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)",
+		"CREATE TABLE posts (id INTEGER PRIMARY KEY, user_id INTEGER, title TEXT)",
+		"CREATE TABLE profiles (user_id INTEGER, bio TEXT)",
+		"INSERT INTO users (id, name) VALUES (1, 'alice'), (2, 'bob')",
+		"INSERT INTO posts (id, user_id, title) VALUES (1, 1, 'first'), (2, 1, 'second'), (3, 2, 'third')",
+		"INSERT INTO profiles (user_id, bio) VALUES (2, 'bob bio')",
+	}
+	for _, s := range stmt {
+		if _, err := db.Exec(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+func TestSelect_preload(t *testing.T) {
+	ctx := context.Background()
+	db := initRelDB(t)
+
+	it, err := Select[relUser](ctx, db, "users", sq.Eq{"id": []int{1, 2}}, Preload("Posts", "Profile"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Collect2(it)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []relUser{
+		{
+			ID:   1,
+			Name: "alice",
+			Posts: []relPost{
+				{ID: 1, UserID: 1, Title: "first"},
+				{ID: 2, UserID: 1, Title: "second"},
+			},
+		},
+		{
+			ID:      2,
+			Name:    "bob",
+			Posts:   []relPost{{ID: 3, UserID: 2, Title: "third"}},
+			Profile: &relProfile{UserID: 2, Bio: "bob bio"},
+		},
+	}
+	assert.Equal(t, want, got)
+}
+
+func TestSelectRow_preload(t *testing.T) {
+	ctx := context.Background()
+	db := initRelDB(t)
+
+	t.Run("belongs to", func(t *testing.T) {
+		got, err := SelectRowByID[relPost](ctx, db, "posts", 3, Preload("Author"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, &relPost{ID: 3, UserID: 2, Title: "third", Author: &relUser{ID: 2, Name: "bob"}}, got)
+	})
+	t.Run("undeclared relation", func(t *testing.T) {
+		_, err := SelectRowByID[relPost](ctx, db, "posts", 3, Preload("Title"))
+		assert.Error(t, err)
+	})
+}
+
+func TestRelate(t *testing.T) {
+	tests := []struct {
+		name string
+		rel  Relation
+	}{
+		{"no field", Relation{Kind: HasOne, Field: "Missing", Table: "posts", ForeignKey: "user_id"}},
+		{"not a slice", Relation{Kind: HasMany, Field: "Profile", Table: "profiles", ForeignKey: "user_id"}},
+		{"not a struct", Relation{Kind: HasOne, Field: "Name", Table: "profiles", ForeignKey: "user_id"}},
+		{"no table", Relation{Kind: HasOne, Field: "Profile", ForeignKey: "user_id"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Panics(t, func() { Relate[relUser](tt.rel) })
+		})
+	}
+	t.Run("not tagged", func(t *testing.T) {
+		type untagged struct {
+			ID    int64     `db:"id"`
+			Posts []relPost // would be mapped to the "Posts" column
+		}
+		assert.PanicsWithValue(t, "sqlhelp: Relate[sqlhelp.untagged]: Posts: relation field must be tagged `db:\"-\"`", func() {
+			Relate[untagged](Relation{Kind: HasMany, Field: "Posts", Table: "posts", ForeignKey: "user_id"})
+		})
+	})
+}
+
+type relAccount struct {
+	ID    int64         `db:"id"`
+	Notes []relUserNote `db:"-"`
+}
+
+type relUserNote struct {
+	ID   int64  `db:"id"`
+	User int64  `db:"user"` // reserved word, quoted in the query
+	Text string `db:"text"`
+}
+
+func TestSelect_preloadReservedKey(t *testing.T) {
+	Relate[relAccount](Relation{Kind: HasMany, Field: "Notes", Table: "notes", ForeignKey: "user"})
+	ctx := context.Background()
+	db := sqlhelptest.InitSqliteDB(t)
+	for _, s := range []string{
+		`CREATE TABLE accounts (id INTEGER PRIMARY KEY)`,
+		`CREATE TABLE notes (id INTEGER PRIMARY KEY, "user" INTEGER, text TEXT)`,
+		`INSERT INTO accounts (id) VALUES (1)`,
+		`INSERT INTO notes (id, "user", text) VALUES (1, 1, 'a'), (2, 1, 'b')`,
+	} {
+		db.MustExec(s)
+	}
+	got, err := SelectRowByID[relAccount](ctx, db, "accounts", 1, Preload("Notes"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []relUserNote{{ID: 1, User: 1, Text: "a"}, {ID: 2, User: 1, Text: "b"}}, got.Notes)
+}
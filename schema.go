@@ -0,0 +1,294 @@
+package sqlhelp
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// In this file: schema generation from the struct definition.  Column types
+// are derived from Go field types, and the following tag options are
+// recognised:
+//
+//   - pk: the column is a primary key.  Single integer primary key becomes
+//     an auto-incrementing column;
+//   - notnull: the column is NOT NULL;
+//   - unique: the column is UNIQUE;
+//   - default=expr: the column DEFAULT value, expr is copied verbatim;
+//   - type=TYPE: overrides the derived column type;
+//   - json: the value is stored as JSON, see [JSONValue];
+//   - encrypted: the value is encrypted and stored as text, see
+//     [ColumnCipher].
+//
+// Slices are stored as arrays on Postgres, and as JSON on SQLite, see
+// [Array].
+
+// colKind is the dialect-neutral kind of the column.
+type colKind int
+
+const (
+	kindInvalid colKind = iota
+	kindBool
+	kindSmallInt
+	kindInt
+	kindBigInt
+	kindReal
+	kindDouble
+	kindText
+	kindBytes
+	kindTime
+	kindJSON
+	kindArray
+)
+
+// sqlNullKinds maps the database/sql Null types to column kinds.
+var sqlNullKinds = map[reflect.Type]colKind{
+	reflect.TypeFor[sql.NullBool]():    kindBool,
+	reflect.TypeFor[sql.NullByte]():    kindSmallInt,
+	reflect.TypeFor[sql.NullInt16]():   kindSmallInt,
+	reflect.TypeFor[sql.NullInt32]():   kindInt,
+	reflect.TypeFor[sql.NullInt64]():   kindBigInt,
+	reflect.TypeFor[sql.NullFloat64](): kindDouble,
+	reflect.TypeFor[sql.NullString]():  kindText,
+	reflect.TypeFor[sql.NullTime]():    kindTime,
+}
+
+// kindOf returns the column kind for the Go type t, and whether the column
+// is nullable.
+func kindOf(t reflect.Type) (colKind, bool) {
+	if k, ok := sqlNullKinds[t]; ok {
+		return k, true
+	}
+	if t.PkgPath() == "database/sql" && strings.HasPrefix(t.Name(), "Null[") {
+		// generic sql.Null[T]
+		k, _ := kindOf(t.Field(0).Type)
+		return k, true
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		k, _ := kindOf(t.Elem())
+		return k, true
+	case reflect.Bool:
+		return kindBool, false
+	case reflect.Int8, reflect.Int16, reflect.Uint8:
+		return kindSmallInt, false
+	case reflect.Int32, reflect.Uint16:
+		return kindInt, false
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return kindBigInt, false
+	case reflect.Float32:
+		return kindReal, false
+	case reflect.Float64:
+		return kindDouble, false
+	case reflect.String:
+		return kindText, false
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return kindBytes, false
+		}
+	case reflect.Struct:
+		if t == timeType {
+			return kindTime, false
+		}
+	}
+	return kindInvalid, false
+}
+
+// kind returns the column kind of the field, and whether the column is
+// nullable.
+func (f field) kind() (colKind, bool) {
+	if f.opts.has("json") {
+		return kindJSON, true
+	}
+	if enc, _ := encryptOpt(f.opts); enc {
+		return kindText, true
+	}
+	if isArray(f.typ) {
+		return kindArray, true
+	}
+	return kindOf(f.typ)
+}
+
+// isInteger returns true if the column kind is an integer.
+func (k colKind) isInteger() bool {
+	return k == kindSmallInt || k == kindInt || k == kindBigInt
+}
+
+// typeName returns the column type name for the dialect.
+func (d Dialect) typeName(k colKind) string {
+	switch d {
+	case DialectPostgres:
+		return [...]string{
+			kindBool:     "BOOLEAN",
+			kindSmallInt: "SMALLINT",
+			kindInt:      "INTEGER",
+			kindBigInt:   "BIGINT",
+			kindReal:     "REAL",
+			kindDouble:   "DOUBLE PRECISION",
+			kindText:     "TEXT",
+			kindBytes:    "BYTEA",
+			kindTime:     "TIMESTAMP WITH TIME ZONE",
+			kindJSON:     "JSONB",
+			kindArray:    "", // see arrayTypeName
+		}[k]
+	case DialectSQLite:
+		return [...]string{
+			kindBool:     "BOOLEAN",
+			kindSmallInt: "INTEGER",
+			kindInt:      "INTEGER",
+			kindBigInt:   "INTEGER",
+			kindReal:     "REAL",
+			kindDouble:   "REAL",
+			kindText:     "TEXT",
+			kindBytes:    "BLOB",
+			kindTime:     "DATETIME",
+			kindJSON:     "TEXT",
+			kindArray:    "TEXT", // stored as JSON
+		}[k]
+	}
+	return ""
+}
+
+// arrayTypeName returns the array type for the slice type t, or an empty
+// string if the element type is not supported.
+func (d Dialect) arrayTypeName(t reflect.Type) string {
+	if d != DialectPostgres {
+		return d.typeName(kindArray)
+	}
+	elem := t.Elem()
+	if elem.Kind() == reflect.Pointer {
+		elem = elem.Elem()
+	}
+	k, _ := kindOf(elem)
+	if k == kindInvalid || k == kindBytes || k == kindTime {
+		return ""
+	}
+	return d.typeName(k) + "[]"
+}
+
+// serialName returns the auto-incrementing integer type for the dialect.
+func (d Dialect) serialName(k colKind) string {
+	switch d {
+	case DialectPostgres:
+		switch k {
+		case kindSmallInt:
+			return "SMALLSERIAL"
+		case kindInt:
+			return "SERIAL"
+		default:
+			return "BIGSERIAL"
+		}
+	case DialectSQLite:
+		return "INTEGER"
+	}
+	return ""
+}
+
+// ErrUnsupportedDialect is returned when the dialect is not supported.
+var ErrUnsupportedDialect = errors.New("unsupported dialect")
+
+// CreateTableSQL returns the CREATE TABLE statement for the table that holds
+// values of the struct type T.  Columns follow the order of the struct
+// fields.
+func CreateTableSQL[T any](d Dialect, table string) (string, error) {
+	if d.typeName(kindText) == "" {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedDialect, d)
+	}
+	table, err := tableOf[T](table)
+	if err != nil {
+		return "", err
+	}
+	name, err := d.Identifier(table)
+	if err != nil {
+		return "", err
+	}
+	ff := fieldsOf(reflect.TypeFor[T]())
+	if len(ff) == 0 {
+		return "", fmt.Errorf("%s: no columns", table)
+	}
+
+	var pk []field
+	for _, f := range ff {
+		if f.opts.has("pk") {
+			pk = append(pk, f)
+		}
+	}
+	// single integer primary key is generated by the database.
+	serial := false
+	if len(pk) == 1 {
+		if _, hasType := pk[0].opts.value("type"); !hasType {
+			k, _ := pk[0].kind()
+			serial = k.isInteger()
+		}
+	}
+
+	defs := make([]string, 0, len(ff)+1)
+	for _, f := range ff {
+		def, err := columnDef(d, f, len(pk) == 1, serial)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", table, err)
+		}
+		defs = append(defs, def)
+	}
+	if len(pk) > 1 {
+		cols := make([]string, len(pk))
+		for i, f := range pk {
+			cols[i] = f.column
+		}
+		if cols, err = d.quoteColumns(cols); err != nil {
+			return "", fmt.Errorf("%s: %w", table, err)
+		}
+		defs = append(defs, "PRIMARY KEY ("+strings.Join(cols, ", ")+")")
+	}
+
+	var buf strings.Builder
+	buf.WriteString("CREATE TABLE " + name + " (\n\t")
+	buf.WriteString(strings.Join(defs, ",\n\t"))
+	buf.WriteString("\n)")
+	return buf.String(), nil
+}
+
+// columnDef returns the column definition for the field f.  If inlinePK is
+// true, the primary key constraint is defined on the column, and if serial
+// is true, the primary key is auto-incrementing.
+func columnDef(d Dialect, f field, inlinePK bool, serial bool) (string, error) {
+	k, _ := f.kind()
+	isPK := f.opts.has("pk")
+
+	typ, hasType := f.opts.value("type")
+	if !hasType {
+		if k == kindInvalid {
+			return "", fmt.Errorf("field %s: unsupported type %s", f.name, f.typ)
+		}
+		switch {
+		case isPK && serial:
+			typ = d.serialName(k)
+		case k == kindArray:
+			if typ = d.arrayTypeName(f.typ); typ == "" {
+				return "", fmt.Errorf("field %s: unsupported array type %s", f.name, f.typ)
+			}
+		default:
+			typ = d.typeName(k)
+		}
+	}
+
+	column, err := d.Identifier(f.column)
+	if err != nil {
+		return "", fmt.Errorf("field %s: %w", f.name, err)
+	}
+	def := []string{column, typ}
+	if isPK && inlinePK {
+		def = append(def, "PRIMARY KEY")
+	} else if f.opts.has("notnull") || isPK {
+		def = append(def, "NOT NULL")
+	}
+	if f.opts.has("unique") {
+		def = append(def, "UNIQUE")
+	}
+	if dflt, ok := f.opts.value("default"); ok {
+		def = append(def, "DEFAULT "+dflt)
+	}
+	return strings.Join(def, " "), nil
+}
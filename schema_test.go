@@ -0,0 +1,165 @@
+package sqlhelp
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/rusq/sqlhelp/sqlhelptest"
+	"github.com/stretchr/testify/assert"
+)
+
+type schemaStruct struct {
+	ID        int64          `db:"id,pk,omitempty"`
+	Name      string         `db:"name,notnull,unique"`
+	Score     float64        `db:"score,default=0"`
+	Active    bool           `db:"active"`
+	Data      []byte         `db:"data"`
+	Note      sql.NullString `db:"note"`
+	Parent    *int32         `db:"parent_id"`
+	CreatedAt time.Time      `db:"created_at,notnull,default=CURRENT_TIMESTAMP"`
+	internal  int
+	Ignored   string `db:"-"`
+}
+
+type compositeStruct struct {
+	TenantID int64  `db:"tenant_id,pk"`
+	Key      string `db:"key,pk"`
+	Value    string `db:"value,type=VARCHAR(255)"`
+}
+
+type unsupportedStruct struct {
+	ID  int64          `db:"id"`
+	Map map[string]int `db:"map"`
+}
+
+func TestCreateTableSQL(t *testing.T) {
+	tests := []struct {
+		name    string
+		fn      func(d Dialect, table string) (string, error)
+		dialect Dialect
+		want    string
+		wantErr bool
+	}{
+		{
+			"postgres",
+			CreateTableSQL[schemaStruct],
+			DialectPostgres,
+			"CREATE TABLE test_table (\n" +
+				"\tid BIGSERIAL PRIMARY KEY,\n" +
+				"\tname TEXT NOT NULL UNIQUE,\n" +
+				"\tscore DOUBLE PRECISION DEFAULT 0,\n" +
+				"\tactive BOOLEAN,\n" +
+				"\tdata BYTEA,\n" +
+				"\tnote TEXT,\n" +
+				"\tparent_id INTEGER,\n" +
+				"\tcreated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP\n" +
+				")",
+			false,
+		},
+		{
+			"sqlite",
+			CreateTableSQL[schemaStruct],
+			DialectSQLite,
+			"CREATE TABLE test_table (\n" +
+				"\tid INTEGER PRIMARY KEY,\n" +
+				"\tname TEXT NOT NULL UNIQUE,\n" +
+				"\tscore REAL DEFAULT 0,\n" +
+				"\tactive BOOLEAN,\n" +
+				"\tdata BLOB,\n" +
+				"\tnote TEXT,\n" +
+				"\tparent_id INTEGER,\n" +
+				"\tcreated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP\n" +
+				")",
+			false,
+		},
+		{
+			"composite primary key",
+			CreateTableSQL[compositeStruct],
+			DialectPostgres,
+			"CREATE TABLE test_table (\n" +
+				"\ttenant_id BIGINT NOT NULL,\n" +
+				"\tkey TEXT NOT NULL,\n" +
+				"\tvalue VARCHAR(255),\n" +
+				"\tPRIMARY KEY (tenant_id, key)\n" +
+				")",
+			false,
+		},
+		{
+			"unsupported type",
+			CreateTableSQL[unsupportedStruct],
+			DialectPostgres,
+			"",
+			true,
+		},
+		{
+			"unsupported dialect",
+			CreateTableSQL[schemaStruct],
+			DialectUnknown,
+			"",
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.fn(tt.dialect, "test_table")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CreateTableSQL() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// tabledSchema is schemaStruct that knows its table.
+type tabledSchema schemaStruct
+
+func (tabledSchema) TableName() string { return "test_table" }
+
+func TestCreateTableSQL_tabler(t *testing.T) {
+	got, err := CreateTableSQL[tabledSchema](DialectSQLite, "")
+	assert.NoError(t, err)
+	assert.Contains(t, got, "CREATE TABLE test_table (")
+
+	_, err = CreateTableSQL[schemaStruct](DialectSQLite, "")
+	assert.ErrorIs(t, err, ErrNoTable)
+}
+
+func TestCreateTableSQL_sqlite(t *testing.T) {
+	// bootstrap the schema from the struct and make a round trip.
+	ctx := context.Background()
+	db := sqlhelptest.InitSqliteDB(t)
+	stmt, err := CreateTableSQL[schemaStruct](DialectOf(db), "test_table")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(ctx, stmt); err != nil {
+		t.Fatal(err)
+	}
+	want := schemaStruct{
+		Name:      "test",
+		Score:     1.5,
+		Active:    true,
+		Data:      []byte("data"),
+		Note:      sql.NullString{String: "note", Valid: true},
+		CreatedAt: testDate,
+	}
+	id, err := Insert(ctx, db, "test_table", want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want.ID = id
+	got, err := SelectRowByID[schemaStruct](ctx, db, "test_table", id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got.CreatedAt = got.CreatedAt.UTC()
+	assert.Equal(t, &want, got)
+
+	// unique constraint is in place.
+	if _, err := db.ExecContext(ctx, "INSERT INTO test_table (name) VALUES ('test')"); err == nil {
+		t.Error("expected unique constraint violation")
+	}
+}
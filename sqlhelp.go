@@ -1,16 +1,24 @@
 // package sqlhelp provides a set of generic helper functions to work with SQL
 // databases.
+//
+// The following applies to all generic helpers that take the table name:
+//   - if the table is empty, the table name of T is used (see [Tabler]);
+//   - if the context carries the schema, the table name is qualified with it
+//     (see [WithSchema]);
+//   - table and column names are validated and quoted where necessary (see
+//     [Dialect.Identifier]).
 package sqlhelp
 
 import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"iter"
+	"reflect"
 
 	sq "github.com/Masterminds/squirrel"
 	"github.com/jmoiron/sqlx"
-	"github.com/rusq/tagops"
 )
 
 var Tag = "db"
@@ -22,9 +30,24 @@ func Insert[T any](ctx context.Context, db sqlx.ExtContext, table string, a T) (
 
 // InsertFull is a generic function to insert a record into a table, if
 // omitEmpty is specified, fields with empty values will be omitted from the
-// insert statement.
+// insert statement.  The record is validated before the insert, see
+// [Validate].
 func InsertFull[T any](ctx context.Context, db sqlx.ExtContext, omitEmpty bool, table string, a T) (int64, error) {
-	bld := sq.Insert(table).SetMap(tagops.ToMap(a, Tag, omitEmpty, true)).Suffix("ON CONFLICT DO NOTHING")
+	table, err := tableOf[T](table)
+	if err != nil {
+		return 0, err
+	}
+	if err := beforeInsert(ctx, &a); err != nil {
+		return 0, err
+	}
+	if err := validate(a); err != nil {
+		return 0, err
+	}
+	name, m, err := writeTarget(ctx, db, table, a, omitEmpty)
+	if err != nil {
+		return 0, err
+	}
+	bld := sq.Insert(name).SetMap(m).Suffix("ON CONFLICT DO NOTHING")
 	stmt, binds, err := bld.ToSql()
 	if err != nil {
 		return 0, err
@@ -38,6 +61,9 @@ func InsertFull[T any](ctx context.Context, db sqlx.ExtContext, omitEmpty bool,
 	if err != nil {
 		return id, err
 	}
+	if err := afterInsert(ctx, &a); err != nil {
+		return id, err
+	}
 	return id, nil
 }
 
@@ -48,7 +74,25 @@ func InsertPSQL[T any](ctx context.Context, db sqlx.ExtContext, table string, id
 
 // InsertPSQLFull is a Postgres flavour of InsertFull.
 func InsertPSQLFull[T any](ctx context.Context, db sqlx.ExtContext, omitEmpty bool, table string, idCol string, a T) (int64, error) {
-	bld := sq.Insert(table).SetMap(tagops.ToMap(a, Tag, omitEmpty, false)).Suffix("ON CONFLICT DO NOTHING RETURNING " + idCol)
+	table, err := tableOf[T](table)
+	if err != nil {
+		return 0, err
+	}
+	if err := beforeInsert(ctx, &a); err != nil {
+		return 0, err
+	}
+	if err := validate(a); err != nil {
+		return 0, err
+	}
+	name, m, err := writeTarget(ctx, db, table, a, omitEmpty)
+	if err != nil {
+		return 0, err
+	}
+	idCol, err = DialectOf(db).Identifier(idCol)
+	if err != nil {
+		return 0, err
+	}
+	bld := sq.Insert(name).SetMap(m).Suffix("ON CONFLICT DO NOTHING RETURNING " + idCol)
 	stmt, binds, err := bld.ToSql()
 	if err != nil {
 		return 0, err
@@ -58,26 +102,65 @@ func InsertPSQLFull[T any](ctx context.Context, db sqlx.ExtContext, omitEmpty bo
 	if err := db.QueryRowxContext(ctx, db.Rebind(stmt), binds...).Scan(&id); err != nil {
 		return 0, err
 	}
+	if err := afterInsert(ctx, &a); err != nil {
+		return id, err
+	}
 	return id, nil
 }
 
 // SelectRow selects a row from a table.
-func SelectRow[T any](ctx context.Context, db sqlx.ExtContext, table string, where sq.Sqlizer) (*T, error) {
+func SelectRow[T any](ctx context.Context, db sqlx.ExtContext, table string, where sq.Sqlizer, opts ...SelectOption) (*T, error) {
+	o := newSelectOptions(opts)
+	table, err := tableOf[T](table)
+	if err != nil {
+		return nil, err
+	}
 	var res T
-	bld := sq.Select(tagops.Tags(&res, Tag)...).From(table).Where(where)
+	bld, err := selectBuilder(ctx, db, table, where, columnsOf(reflect.TypeFor[T]()))
+	if err != nil {
+		return nil, err
+	}
 	query, args, err := bld.ToSql()
 	if err != nil {
 		return nil, err
 	}
-	if err := db.QueryRowxContext(ctx, db.Rebind(query), args...).StructScan(&res); err != nil {
+	if err := scanStruct(db.QueryRowxContext(ctx, db.Rebind(query), args...), &res); err != nil {
+		return nil, err
+	}
+	if len(o.preload) > 0 {
+		rv := reflect.ValueOf([]T{res})
+		if err := preload(ctx, db, rv, o.preload); err != nil {
+			return nil, err
+		}
+		res = rv.Index(0).Interface().(T)
+	}
+	if err := afterSelect(ctx, &res); err != nil {
 		return nil, err
 	}
 	return &res, nil
 }
 
-// Update updates a record.
+// Update updates a record.  The record is validated before the update, see
+// [Validate], the fields omitted from the update are not checked.
 func Update[T any](ctx context.Context, db sqlx.ExtContext, table string, a *T, where sq.Sqlizer) (int64, error) {
-	bld := sq.Update(table).SetMap(tagops.ToMap(a, Tag, true, false)).Where(where)
+	table, err := tableOf[T](table)
+	if err != nil {
+		return 0, err
+	}
+	if err := beforeUpdate(ctx, a); err != nil {
+		return 0, err
+	}
+	if err := validatePartial(a); err != nil {
+		return 0, err
+	}
+	name, m, err := writeTarget(ctx, db, table, a, true)
+	if err != nil {
+		return 0, err
+	}
+	if where, err = tenantWhere(ctx, DialectOf(db), table, "", where); err != nil {
+		return 0, err
+	}
+	bld := sq.Update(name).SetMap(m).Where(where)
 	query, args, err := bld.ToSql()
 	if err != nil {
 		return 0, err
@@ -93,6 +176,43 @@ func Update[T any](ctx context.Context, db sqlx.ExtContext, table string, a *T,
 	return raff, err
 }
 
+// writeTarget returns the table name and the column map of a for the
+// INSERT and UPDATE statements, with the names validated and quoted, and
+// the tenant stamped.
+func writeTarget(ctx context.Context, db sqlx.ExtContext, table string, a any, omitEmpty bool) (string, map[string]any, error) {
+	name, err := tableName(ctx, db, table)
+	if err != nil {
+		return "", nil, err
+	}
+	d := DialectOf(db)
+	m := toMap(d, a, omitEmpty)
+	if err := stampTenant(ctx, table, m); err != nil {
+		return "", nil, err
+	}
+	if m, err = d.quoteMap(m); err != nil {
+		return "", nil, err
+	}
+	return name, m, nil
+}
+
+// selectBuilder returns the SELECT builder for the columns from the table,
+// with the names validated and quoted, and the tenant filter applied.
+func selectBuilder(ctx context.Context, db sqlx.ExtContext, table string, where sq.Sqlizer, columns []string) (sq.SelectBuilder, error) {
+	name, err := tableName(ctx, db, table)
+	if err != nil {
+		return sq.SelectBuilder{}, err
+	}
+	d := DialectOf(db)
+	if where, err = tenantWhere(ctx, d, table, "", where); err != nil {
+		return sq.SelectBuilder{}, err
+	}
+	cols, err := d.quoteColumns(columns)
+	if err != nil {
+		return sq.SelectBuilder{}, err
+	}
+	return sq.Select(cols...).From(name).Where(where), nil
+}
+
 // JustErr is a helper function to return just an error from a function that
 // returns two values, where the first one is not needed and the second is an
 // error.
@@ -100,9 +220,30 @@ func JustErr(_ any, err error) error {
 	return err
 }
 
-// Delete deletes rows from the table matching where argument.
+// DeleteOf deletes rows matching where argument from the table of T, see
+// [Tabler].  If T implements [BeforeDeleter], it is called before the delete.
+func DeleteOf[T any](ctx context.Context, db sqlx.ExtContext, where sq.Sqlizer) error {
+	table, err := tableOf[T]("")
+	if err != nil {
+		return err
+	}
+	if err := beforeDelete[T](ctx, where); err != nil {
+		return err
+	}
+	return Delete(ctx, db, table, where)
+}
+
+// Delete deletes rows from the table matching where argument.  It does not
+// call the hooks, as the type is unknown, use [DeleteOf] instead.
 func Delete(ctx context.Context, db sqlx.ExtContext, table string, where sq.Sqlizer) error {
-	bld := sq.Delete(table).Where(where)
+	name, err := tableName(ctx, db, table)
+	if err != nil {
+		return err
+	}
+	if where, err = tenantWhere(ctx, DialectOf(db), table, "", where); err != nil {
+		return err
+	}
+	bld := sq.Delete(name).Where(where)
 	query, args, err := bld.ToSql()
 	if err != nil {
 		return err
@@ -114,10 +255,52 @@ func Delete(ctx context.Context, db sqlx.ExtContext, table string, where sq.Sqli
 	return nil
 }
 
-// Select selects rows from a table.
-func Select[T any](ctx context.Context, db sqlx.ExtContext, table string, where sq.Sqlizer) (iter.Seq2[T, error], error) {
-	var t T
-	bld := sq.Select(tagops.Tags(&t, Tag)...).From(table).Where(where)
+// Select selects rows from a table.  If related rows are preloaded (see
+// [Preload]), all rows are fetched before Select returns.  If T is not a
+// struct, i.e. int64 or string, the single column set with [ScalarColumn] is
+// selected and scanned into T.
+func Select[T any](ctx context.Context, db sqlx.ExtContext, table string, where sq.Sqlizer, opts ...SelectOption) (iter.Seq2[T, error], error) {
+	o := newSelectOptions(opts)
+	typ := reflect.TypeFor[T]()
+	columns := []string{o.column}
+	if !isScalar(typ) {
+		columns = columnsOf(typ)
+	} else if o.column == "" {
+		return nil, fmt.Errorf("%s is not a struct, set the column with the ScalarColumn option", typ)
+	} else if len(o.preload) > 0 {
+		return nil, fmt.Errorf("%s is not a struct, can't preload", typ)
+	}
+	table, err := tableOf[T](table)
+	if err != nil {
+		return nil, err
+	}
+	bld, err := selectBuilder(ctx, db, table, where, columns)
+	if err != nil {
+		return nil, err
+	}
+	query, args, err := bld.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.QueryxContext(ctx, db.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(o.preload) > 0 {
+		return selectPreload[T](ctx, db, rows, o.preload)
+	}
+	return rowsIter[T](ctx, rows), nil
+}
+
+// SelectColumn selects a single column from a table, scanning values into
+// V, which would normally be a primitive type, i.e. int64 or string.  The
+// column must be the column name, expressions are rejected, see
+// [Dialect.Identifier].
+func SelectColumn[V any](ctx context.Context, db sqlx.ExtContext, table string, column string, where sq.Sqlizer) (iter.Seq2[V, error], error) {
+	bld, err := selectBuilder(ctx, db, table, where, []string{column})
+	if err != nil {
+		return nil, err
+	}
 	query, args, err := bld.ToSql()
 	if err != nil {
 		return nil, err
@@ -126,11 +309,20 @@ func Select[T any](ctx context.Context, db sqlx.ExtContext, table string, where
 	if err != nil {
 		return nil, err
 	}
-	iterFunc := func(yield func(T, error) bool) {
+	return rowsIter[V](ctx, rows), nil
+}
+
+// rowsIter returns the iterator over rows, that scans each row into T.  Rows
+// are closed when the iteration is complete.
+func rowsIter[T any](ctx context.Context, rows *sqlx.Rows) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
 		defer rows.Close()
 		for rows.Next() {
 			var t T
-			err := rows.StructScan(&t)
+			err := scanRow(rows, &t)
+			if err == nil {
+				err = afterSelect(ctx, &t)
+			}
 			if !yield(t, err) {
 				return
 			}
@@ -140,7 +332,72 @@ func Select[T any](ctx context.Context, db sqlx.ExtContext, table string, where
 			yield(t2, err)
 		}
 	}
-	return iterFunc, nil
+}
+
+// rowScanner is the common interface of [sqlx.Rows] and [sqlx.Row].
+type rowScanner interface {
+	Columns() ([]string, error)
+	Scan(dest ...any) error
+	StructScan(dest any) error
+}
+
+// scanRow scans the row into t.  Structs are scanned with [scanStruct], and
+// scalar types, such as int64, string or time.Time, are scanned with Scan.
+func scanRow[T any](r rowScanner, t *T) error {
+	if isScalar(reflect.TypeFor[T]()) {
+		return r.Scan(t)
+	}
+	return scanStruct(r, t)
+}
+
+// scanStruct scans the row into the struct pointed to by dest.  If dest
+// implements [RowScanner], it is used instead of StructScan.  Structs with
+// JSON or array fields are scanned with scanFields.
+func scanStruct(r rowScanner, dest any) error {
+	if rs, ok := dest.(RowScanner); ok {
+		cols, err := r.Columns()
+		if err != nil {
+			return err
+		}
+		return rs.ScanRow(cols, r.Scan)
+	}
+	if needsFieldScan(reflect.TypeOf(dest).Elem()) {
+		return scanFields(r, dest)
+	}
+	return r.StructScan(dest)
+}
+
+// selectPreload fetches all rows, preloads related rows and returns the
+// iterator over the result.
+func selectPreload[T any](ctx context.Context, db sqlx.ExtContext, rows *sqlx.Rows, fields []string) (iter.Seq2[T, error], error) {
+	var res []T
+	for rows.Next() {
+		var t T
+		if err := scanStruct(rows, &t); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		res = append(res, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := preload(ctx, db, reflect.ValueOf(res), fields); err != nil {
+		return nil, err
+	}
+	for i := range res {
+		if err := afterSelect(ctx, &res[i]); err != nil {
+			return nil, err
+		}
+	}
+	return func(yield func(T, error) bool) {
+		for _, t := range res {
+			if !yield(t, nil) {
+				return
+			}
+		}
+	}, nil
 }
 
 // Collect2 collects all values from the iterator into a slice.
@@ -155,8 +412,26 @@ func Collect2[T any](seq iter.Seq2[T, error]) ([]T, error) {
 	return res, nil
 }
 
+// ExistsOf checks if rows matching where argument exist in the table of T,
+// see [Tabler].
+func ExistsOf[T any](ctx context.Context, db sqlx.ExtContext, where sq.Sqlizer) (bool, error) {
+	table, err := tableOf[T]("")
+	if err != nil {
+		return false, err
+	}
+	return Exists(ctx, db, table, where)
+}
+
+// Exists checks if rows matching where argument exist in the table.
 func Exists(ctx context.Context, db sqlx.ExtContext, table string, where sq.Sqlizer) (bool, error) {
-	bld := sq.Select("1 as X").From(table).Where(where)
+	name, err := tableName(ctx, db, table)
+	if err != nil {
+		return false, err
+	}
+	if where, err = tenantWhere(ctx, DialectOf(db), table, "", where); err != nil {
+		return false, err
+	}
+	bld := sq.Select("1 as X").From(name).Where(where)
 	query, args, err := bld.ToSql()
 	if err != nil {
 		return false, err
@@ -698,3 +698,43 @@ func TestCollect2(t *testing.T) {
 		assert.ErrorIs(t, err, assert.AnError)
 	})
 }
+
+func TestSelectColumn(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		db, mock := sqlhelptest.InitMockDB(t)
+		mock.ExpectQuery(`SELECT id FROM test_table WHERE name = \$1`).
+			WithArgs("test").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2)).
+			RowsWillBeClosed()
+		iter, err := SelectColumn[int64](context.Background(), db, "test_table", "id", sq.Eq{"name": "test"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := Collect2(iter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, []int64{1, 2}, got)
+	})
+	t.Run("select scalar", func(t *testing.T) {
+		db, mock := sqlhelptest.InitMockDB(t)
+		mock.ExpectQuery(`SELECT name FROM test_table WHERE id > \$1`).
+			WithArgs(0).
+			WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("a").AddRow("b")).
+			RowsWillBeClosed()
+		iter, err := Select[string](context.Background(), db, "test_table", sq.Gt{"id": 0}, ScalarColumn("name"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := Collect2(iter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, []string{"a", "b"}, got)
+	})
+	t.Run("select scalar without column", func(t *testing.T) {
+		db, _ := sqlhelptest.InitMockDB(t)
+		_, err := Select[int64](context.Background(), db, "test_table", sq.Eq{"name": "test"})
+		assert.Error(t, err)
+	})
+}
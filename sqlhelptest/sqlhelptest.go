@@ -54,6 +54,11 @@ func InitMockDB(t *testing.T) (*sqlx.DB, sqlmock.Sqlmock) {
 	return dbx, mock
 }
 
+// InitSqliteDB returns the in-memory SQLite database for testing, that is
+// closed on cleanup.  The in-memory database exists only within a single
+// connection, so the pool is limited to one connection, and all queries see
+// the same database.  The caller must import the "modernc.org/sqlite"
+// driver.
 func InitSqliteDB(t *testing.T) *sqlx.DB {
 	t.Helper()
 
@@ -61,6 +66,7 @@ func InitSqliteDB(t *testing.T) *sqlx.DB {
 	if err != nil {
 		t.Fatal(err)
 	}
+	db.SetMaxOpenConns(1)
 	t.Cleanup(func() {
 		db.Close()
 	})
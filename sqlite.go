@@ -0,0 +1,93 @@
+package sqlhelp
+
+import (
+	"maps"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SQLiteConfig is the SQLite connection configuration for the
+// modernc.org/sqlite driver.  Pragmas are passed as the "_pragma" URI
+// parameters, and are executed on each new connection, i.e.:
+//
+//	cfg := NewSQLiteConfig("app.db").SetForeignKeys(true).SetJournalMode("WAL")
+//	// app.db?_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)
+//
+// Each connection to ":memory:" opens a separate database, set
+// Pool.MaxOpenConns to 1 to share it.
+type SQLiteConfig struct {
+	Path    string            // file path, or ":memory:"
+	Pragmas map[string]string // pragma name to value
+	Params  map[string]string // other URI parameters, i.e. _txlock
+	Driver  string            // driver name, defaults to "sqlite"
+	Pool
+}
+
+var _ Config = (*SQLiteConfig)(nil)
+
+// NewSQLiteConfig returns the config for the database file at path.
+func NewSQLiteConfig(path string) *SQLiteConfig {
+	return &SQLiteConfig{
+		Path:    path,
+		Pragmas: make(map[string]string),
+		Params:  make(map[string]string),
+	}
+}
+
+// DriverName returns the driver name.
+func (cfg *SQLiteConfig) DriverName() string {
+	if cfg.Driver == "" {
+		return "sqlite"
+	}
+	return cfg.Driver
+}
+
+// SetPragma sets the pragma.  Empty value removes it.
+func (cfg *SQLiteConfig) SetPragma(name, value string) *SQLiteConfig {
+	if cfg.Pragmas == nil {
+		cfg.Pragmas = make(map[string]string)
+	}
+	if value == "" {
+		delete(cfg.Pragmas, name)
+	} else {
+		cfg.Pragmas[name] = value
+	}
+	return cfg
+}
+
+// SetForeignKeys enables or disables the foreign key constraints.
+func (cfg *SQLiteConfig) SetForeignKeys(enabled bool) *SQLiteConfig {
+	v := "0"
+	if enabled {
+		v = "1"
+	}
+	return cfg.SetPragma("foreign_keys", v)
+}
+
+// SetJournalMode sets the journal mode, i.e. "WAL".
+func (cfg *SQLiteConfig) SetJournalMode(mode string) *SQLiteConfig {
+	return cfg.SetPragma("journal_mode", mode)
+}
+
+// SetBusyTimeout sets the time to wait for the locked database.
+func (cfg *SQLiteConfig) SetBusyTimeout(d time.Duration) *SQLiteConfig {
+	return cfg.SetPragma("busy_timeout", strconv.FormatInt(d.Milliseconds(), 10))
+}
+
+// DSN returns the connection string.  Pragmas are sorted by name.
+func (cfg *SQLiteConfig) DSN() string {
+	var params []string
+	for _, k := range slices.Sorted(maps.Keys(cfg.Params)) {
+		params = append(params, url.QueryEscape(k)+"="+url.QueryEscape(cfg.Params[k]))
+	}
+	for _, k := range slices.Sorted(maps.Keys(cfg.Pragmas)) {
+		params = append(params, "_pragma="+url.QueryEscape(k+"("+cfg.Pragmas[k]+")"))
+	}
+	if len(params) == 0 {
+		return cfg.Path
+	}
+	return cfg.Path + "?" + strings.Join(params, "&")
+}
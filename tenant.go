@@ -0,0 +1,234 @@
+package sqlhelp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+)
+
+// In this file: multi-tenancy.  With the schema-per-tenant setup, the schema
+// is carried in the context, and the helpers qualify the table names with
+// it, so that one connection pool can serve many tenants:
+//
+//	ctx = WithSchema(ctx, "tenant_a")
+//	Select[User](ctx, db, "users", nil) // SELECT ... FROM tenant_a.users
+//
+// Alternatively, [SetSearchPath] sets the search path for the duration of
+// the transaction, which also applies to the raw queries.
+
+type schemaKey struct{}
+
+// WithSchema returns the context that carries the schema.  Table names
+// used by the helpers with this context are qualified with the schema,
+// unless they are already qualified.
+func WithSchema(ctx context.Context, schema string) context.Context {
+	return context.WithValue(ctx, schemaKey{}, schema)
+}
+
+// SchemaFromContext returns the schema from the context.
+func SchemaFromContext(ctx context.Context) (string, bool) {
+	schema, ok := ctx.Value(schemaKey{}).(string)
+	return schema, ok && schema != ""
+}
+
+// tableName validates and quotes the table name, and qualifies it with the
+// schema from the context, if any.
+func tableName(ctx context.Context, db interface{ DriverName() string }, table string) (string, error) {
+	d := DialectOf(db)
+	name, err := d.Identifier(table)
+	if err != nil {
+		return "", err
+	}
+	schema, ok := SchemaFromContext(ctx)
+	if parts, _ := splitIdent(d, table); !ok || len(parts) > 1 {
+		return name, nil
+	}
+	qs, err := schemaIdent(d, schema)
+	if err != nil {
+		return "", err
+	}
+	return qs + "." + name, nil
+}
+
+// schemaIdent validates the schema name and quotes it, if needed, so that
+// the unquoted names are folded by the database the same way as in the DDL.
+func schemaIdent(d Dialect, schema string) (string, error) {
+	parts, err := splitIdent(d, schema)
+	if err != nil {
+		return "", err
+	}
+	if len(parts) != 1 {
+		return "", fmt.Errorf("%w: schema %q", ErrInvalidIdent, schema)
+	}
+	return d.Identifier(schema)
+}
+
+// ErrNoSchema is returned by [SetSearchPath] if no schema is given, and the
+// context does not carry one.
+var ErrNoSchema = errors.New("no schema")
+
+// SetSearchPath sets the search path for the transaction tx with SET LOCAL,
+// the setting is reverted when the transaction ends.  If schema is empty,
+// the schema from the context is used, see [WithSchema].  Postgres only.
+func SetSearchPath(ctx context.Context, tx *sqlx.Tx, schema ...string) error {
+	if len(schema) == 0 {
+		s, ok := SchemaFromContext(ctx)
+		if !ok {
+			return ErrNoSchema
+		}
+		schema = []string{s}
+	}
+	d := DialectOf(tx)
+	if d != DialectPostgres {
+		return ErrUnsupportedDialect
+	}
+	quoted := make([]string, len(schema))
+	for i, s := range schema {
+		q, err := schemaIdent(d, s)
+		if err != nil {
+			return err
+		}
+		quoted[i] = q
+	}
+	_, err := tx.ExecContext(ctx, "SET LOCAL search_path TO "+strings.Join(quoted, ", "))
+	return err
+}
+
+// Row-level tenancy.  Tables registered with [RegisterTenantTable] hold the
+// rows of many tenants, distinguished by the tenant column.  The helpers
+// add the "column = tenant" condition to the where clauses of the queries
+// on these tables, and stamp the tenant on the inserted and updated rows,
+// taking the tenant from the context:
+//
+//	RegisterTenantTable("orders", "tenant_id")
+//	ctx = WithTenant(ctx, 42)
+//	Select[Order](ctx, db, "orders", sq.Eq{"status": "new"})
+//	// SELECT ... FROM orders WHERE (status = $1 AND tenant_id = $2)
+//
+// If the context has no tenant, the helpers fail with [ErrNoTenant], unless
+// the context is created with [WithAllTenants].  Raw queries, such as
+// [Query], are not filtered.
+
+// tenantTables maps the table name to the tenant column.
+var tenantTables sync.Map
+
+// RegisterTenantTable registers the table as the tenant-scoped table, with
+// the tenant id stored in the column.  It panics if the table or column
+// name is invalid, see [ValidateIdent].
+func RegisterTenantTable(table, column string) {
+	for _, name := range []string{table, column} {
+		if err := ValidateIdent(name); err != nil {
+			panic(fmt.Sprintf("sqlhelp: RegisterTenantTable: %s", err))
+		}
+	}
+	tenantTables.Store(tenantTableKey(table), column)
+}
+
+// UnregisterTenantTable removes the table registration.
+func UnregisterTenantTable(table string) {
+	tenantTables.Delete(tenantTableKey(table))
+}
+
+// tenantTableKey returns the normalised table name, that the tenant tables
+// are keyed by, so that "orders", "ORDERS", "\"orders\"" and "s.orders" all
+// refer to the same table.  The schema is dropped, quoted names are
+// unquoted, and unquoted names are folded to lower case.
+func tenantTableKey(table string) string {
+	parts, err := splitAnyIdent(table)
+	if err != nil {
+		return table
+	}
+	name, quoted := unquotePart(parts[len(parts)-1])
+	if quoted {
+		return name
+	}
+	return strings.ToLower(name)
+}
+
+// tenantColumn returns the tenant column of the table.
+func tenantColumn(table string) (string, bool) {
+	col, ok := tenantTables.Load(tenantTableKey(table))
+	if !ok {
+		return "", false
+	}
+	return col.(string), true
+}
+
+type (
+	tenantKey     struct{}
+	allTenantsKey struct{}
+)
+
+// ErrNoTenant is returned when the query on the tenant-scoped table is made
+// with the context that has no tenant.
+var ErrNoTenant = errors.New("no tenant in context")
+
+// WithTenant returns the context that carries the tenant id.
+func WithTenant(ctx context.Context, id any) context.Context {
+	return context.WithValue(ctx, tenantKey{}, id)
+}
+
+// TenantFromContext returns the tenant id from the context.
+func TenantFromContext(ctx context.Context) (any, bool) {
+	id := ctx.Value(tenantKey{})
+	return id, id != nil
+}
+
+// WithAllTenants returns the context that disables the tenant filter, for
+// the administrative tasks that span all tenants.
+func WithAllTenants(ctx context.Context) context.Context {
+	return context.WithValue(ctx, allTenantsKey{}, true)
+}
+
+// tenantOf returns the tenant column and id for the table.  ok is false if
+// the table is not tenant-scoped, or the filter is disabled.
+func tenantOf(ctx context.Context, table string) (column string, id any, ok bool, err error) {
+	column, scoped := tenantColumn(table)
+	if !scoped {
+		return "", nil, false, nil
+	}
+	if all, _ := ctx.Value(allTenantsKey{}).(bool); all {
+		return "", nil, false, nil
+	}
+	id, found := TenantFromContext(ctx)
+	if !found {
+		return "", nil, false, fmt.Errorf("%w: table %s", ErrNoTenant, table)
+	}
+	return column, id, true, nil
+}
+
+// tenantWhere adds the tenant condition to where, if the table is
+// tenant-scoped.  If alias is not empty, the column is prefixed with it.
+func tenantWhere(ctx context.Context, d Dialect, table, alias string, where sq.Sqlizer) (sq.Sqlizer, error) {
+	column, id, ok, err := tenantOf(ctx, table)
+	if err != nil || !ok {
+		return where, err
+	}
+	if alias != "" {
+		column = alias + "." + column
+	}
+	if column, err = d.Identifier(column); err != nil {
+		return nil, err
+	}
+	cond := sq.Eq{column: id}
+	if where == nil {
+		return cond, nil
+	}
+	return sq.And{where, cond}, nil
+}
+
+// stampTenant sets the tenant column in the column map m, if the table is
+// tenant-scoped.
+func stampTenant(ctx context.Context, table string, m map[string]any) error {
+	column, id, ok, err := tenantOf(ctx, table)
+	if err != nil || !ok {
+		return err
+	}
+	m[column] = id
+	return nil
+}
@@ -0,0 +1,359 @@
+package sqlhelp
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+	"github.com/rusq/sqlhelp/sqlhelptest"
+	"github.com/stretchr/testify/assert"
+)
+
+type tenantUser struct {
+	ID   int64  `db:"id,omitempty"`
+	Name string `db:"name"`
+}
+
+func TestDialect_QuoteIdent(t *testing.T) {
+	tests := []struct {
+		d    Dialect
+		name string
+		want string
+	}{
+		{DialectPostgres, "tenant_a", `"tenant_a"`},
+		{DialectPostgres, `we"ird`, `"we""ird"`},
+		{DialectSQLite, "main", `"main"`},
+		{DialectMySQL, "we`ird", "`we``ird`"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, tt.d.QuoteIdent(tt.name))
+	}
+}
+
+func TestWithSchema(t *testing.T) {
+	ctx := WithSchema(context.Background(), "tenant_a")
+	t.Run("insert", func(t *testing.T) {
+		db, mock := sqlhelptest.InitMockDB(t)
+		mock.ExpectQuery(`INSERT INTO tenant_a.users \(name\) VALUES \(\$1\)`).
+			WithArgs("bob").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		_, err := InsertPSQL(ctx, db, "users", "id", tenantUser{Name: "bob"})
+		assert.NoError(t, err)
+	})
+	t.Run("select", func(t *testing.T) {
+		db, mock := sqlhelptest.InitMockDB(t)
+		mock.ExpectQuery(`SELECT id, name FROM tenant_a.users WHERE id = \$1`).
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "bob"))
+		_, err := SelectRow[tenantUser](ctx, db, "users", sq.Eq{"id": 1})
+		assert.NoError(t, err)
+	})
+	t.Run("update and delete", func(t *testing.T) {
+		db, mock := sqlhelptest.InitMockDB(t)
+		mock.ExpectExec(`UPDATE tenant_a.users SET name = \$1 WHERE id = \$2`).
+			WithArgs("bob", 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`DELETE FROM tenant_a.users WHERE id = \$1`).
+			WithArgs(1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		_, err := UpdateByID(ctx, db, "users", 1, &tenantUser{Name: "bob"})
+		assert.NoError(t, err)
+		assert.NoError(t, DeleteByID(ctx, db, "users", 1))
+	})
+	t.Run("already qualified", func(t *testing.T) {
+		db, mock := sqlhelptest.InitMockDB(t)
+		mock.ExpectQuery(`SELECT 1 as X FROM public.users WHERE id = \$1`).
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"x"}).AddRow(1))
+		ok, err := ExistsByID(ctx, db, "public.users", 1)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+	t.Run("mixed case and reserved", func(t *testing.T) {
+		db, mock := sqlhelptest.InitMockDB(t)
+		// unquoted, so that postgres folds it to tenanta, as in CREATE SCHEMA TenantA.
+		mock.ExpectExec(`DELETE FROM TenantA.users WHERE id = \$1`).
+			WithArgs(1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`DELETE FROM "user".users WHERE id = \$1`).
+			WithArgs(1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		assert.NoError(t, DeleteByID(WithSchema(context.Background(), "TenantA"), db, "users", 1))
+		assert.NoError(t, DeleteByID(WithSchema(context.Background(), "user"), db, "users", 1))
+	})
+	t.Run("invalid schema", func(t *testing.T) {
+		db, _ := sqlhelptest.InitMockDB(t)
+		for _, schema := range []string{"a; DROP TABLE users", "a.b"} {
+			err := DeleteByID(WithSchema(context.Background(), schema), db, "users", 1)
+			assert.ErrorIs(t, err, ErrInvalidIdent, schema)
+		}
+	})
+	t.Run("no schema", func(t *testing.T) {
+		_, ok := SchemaFromContext(WithSchema(context.Background(), ""))
+		assert.False(t, ok)
+	})
+}
+
+func TestSetSearchPath(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		schema   []string
+		expectFn sqlhelptest.ExpectFunc
+		wantErr  error
+	}{
+		{
+			"explicit",
+			ctx,
+			[]string{"tenant_a", "public"},
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(`SET LOCAL search_path TO tenant_a, public`).WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			nil,
+		},
+		{
+			"from context",
+			WithSchema(ctx, "tenant_b"),
+			nil,
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(`SET LOCAL search_path TO tenant_b`).WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			nil,
+		},
+		{"no schema", ctx, nil, func(sqlmock.Sqlmock) {}, ErrNoSchema},
+		{"invalid schema", ctx, []string{"a, b"}, func(sqlmock.Sqlmock) {}, ErrInvalidIdent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := sqlhelptest.InitMockDB(t)
+			mock.ExpectBegin()
+			tt.expectFn(mock)
+			tx, err := db.Beginx()
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.ErrorIs(t, SetSearchPath(tt.ctx, tx, tt.schema...), tt.wantErr)
+		})
+	}
+	t.Run("sqlite", func(t *testing.T) {
+		db := sqlhelptest.InitSqliteDB(t)
+		tx, err := db.Beginx()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tx.Rollback()
+		assert.ErrorIs(t, SetSearchPath(ctx, tx, "main"), ErrUnsupportedDialect)
+	})
+}
+
+func TestRowTenancy(t *testing.T) {
+	RegisterTenantTable("orders", "tenant_id")
+	RegisterTenantTable("items", "tenant_id")
+	t.Cleanup(func() {
+		UnregisterTenantTable("orders")
+		UnregisterTenantTable("items")
+	})
+	type order struct {
+		ID       int64  `db:"id,omitempty"`
+		TenantID int64  `db:"tenant_id,omitempty"`
+		Status   string `db:"status"`
+	}
+	type orderItem struct {
+		Order order `db:"order"`
+		Item  struct {
+			ID int64 `db:"id"`
+		} `db:"item"`
+	}
+	ctx := WithTenant(context.Background(), 42)
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		expectFn sqlhelptest.ExpectFunc
+		fn       func(db *sqlx.DB, ctx context.Context) error
+		wantErr  error
+	}{
+		{
+			"select",
+			ctx,
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`SELECT id, status, tenant_id FROM orders WHERE \(status = \$1 AND tenant_id = \$2\)`).
+					WithArgs("new", 42).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "status", "tenant_id"}))
+			},
+			func(db *sqlx.DB, ctx context.Context) error {
+				it, err := Select[order](ctx, db, "orders", sq.Eq{"status": "new"})
+				if err != nil {
+					return err
+				}
+				_, err = Collect2(it)
+				return err
+			},
+			nil,
+		},
+		{
+			"select row without where",
+			ctx,
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`SELECT id, status, tenant_id FROM s.orders WHERE tenant_id = \$1`).
+					WithArgs(42).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "status", "tenant_id"}).AddRow(1, "new", 42))
+			},
+			func(db *sqlx.DB, ctx context.Context) error {
+				return JustErr(SelectRow[order](WithSchema(ctx, "s"), db, "orders", nil))
+			},
+			nil,
+		},
+		{
+			"insert stamps tenant",
+			ctx,
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`INSERT INTO orders \(status,tenant_id\) VALUES \(\$1,\$2\)`).
+					WithArgs("new", 42).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+			},
+			func(db *sqlx.DB, ctx context.Context) error {
+				return JustErr(InsertPSQL(ctx, db, "orders", "id", order{TenantID: 7, Status: "new"}))
+			},
+			nil,
+		},
+		{
+			"update",
+			ctx,
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(`UPDATE orders SET status = \$1, tenant_id = \$2 WHERE \(id = \$3 AND tenant_id = \$4\)`).
+					WithArgs("done", 42, 1, 42).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			func(db *sqlx.DB, ctx context.Context) error {
+				return JustErr(UpdateByID(ctx, db, "orders", 1, &order{Status: "done"}))
+			},
+			nil,
+		},
+		{
+			"delete and exists",
+			ctx,
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(`DELETE FROM orders WHERE \(id = \$1 AND tenant_id = \$2\)`).
+					WithArgs(1, 42).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery(`SELECT 1 as X FROM orders WHERE \(id = \$1 AND tenant_id = \$2\)`).
+					WithArgs(1, 42).
+					WillReturnRows(sqlmock.NewRows([]string{"x"}))
+			},
+			func(db *sqlx.DB, ctx context.Context) error {
+				if err := DeleteByID(ctx, db, "orders", 1); err != nil {
+					return err
+				}
+				return JustErr(ExistsByID(ctx, db, "orders", 1))
+			},
+			nil,
+		},
+		{
+			"join",
+			ctx,
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`SELECT .* FROM orders o LEFT JOIN items i ON \(i.order_id = o.id\) AND i.tenant_id = \$1 WHERE o.tenant_id = \$2`).
+					WithArgs(42, 42).
+					WillReturnRows(sqlmock.NewRows([]string{"order.id"}))
+			},
+			func(db *sqlx.DB, ctx context.Context) error {
+				_, err := SelectJoin[orderItem](ctx, db, []Join{
+					{Table: "orders", Alias: "o", Prefix: "order"},
+					{Table: "items", Alias: "i", Prefix: "item", Type: "LEFT JOIN", On: "i.order_id = o.id"},
+				}, nil)
+				return err
+			},
+			nil,
+		},
+		{
+			"quoted, upper-case and qualified names",
+			ctx,
+			func(mock sqlmock.Sqlmock) {
+				for _, table := range []string{`"orders"`, `ORDERS`, `s.orders`, `"s"."orders"`} {
+					mock.ExpectQuery(`SELECT id, status, tenant_id FROM ` + regexp.QuoteMeta(table) + ` WHERE tenant_id = \$1`).
+						WithArgs(42).
+						WillReturnRows(sqlmock.NewRows([]string{"id", "status", "tenant_id"}).AddRow(1, "new", 42))
+				}
+			},
+			func(db *sqlx.DB, ctx context.Context) error {
+				for _, table := range []string{`"orders"`, `ORDERS`, `s.orders`, `"s"."orders"`} {
+					if _, err := SelectRow[order](ctx, db, table, nil); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			nil,
+		},
+		{
+			"unscoped table",
+			context.Background(),
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(`DELETE FROM users WHERE id = \$1`).
+					WithArgs(1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			func(db *sqlx.DB, ctx context.Context) error {
+				return DeleteByID(ctx, db, "users", 1)
+			},
+			nil,
+		},
+		{
+			"all tenants",
+			WithAllTenants(context.Background()),
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(`DELETE FROM orders WHERE id = \$1`).
+					WithArgs(1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			func(db *sqlx.DB, ctx context.Context) error {
+				return DeleteByID(ctx, db, "orders", 1)
+			},
+			nil,
+		},
+		{
+			"no tenant fails closed",
+			context.Background(),
+			func(mock sqlmock.Sqlmock) {},
+			func(db *sqlx.DB, ctx context.Context) error {
+				if _, err := Select[order](ctx, db, "orders", nil); err == nil {
+					return nil
+				}
+				if _, err := Insert(ctx, db, "orders", order{}); err == nil {
+					return nil
+				}
+				if _, err := Update(ctx, db, "orders", &order{}, sq.Eq{"id": 1}); err == nil {
+					return nil
+				}
+				if _, err := Exists(ctx, db, "public.orders", nil); err == nil {
+					return nil
+				}
+				if _, err := SelectRow[order](ctx, db, `"orders"`, nil); err == nil {
+					return nil
+				}
+				if _, err := SelectRow[order](ctx, db, "ORDERS", nil); err == nil {
+					return nil
+				}
+				return Delete(ctx, db, "orders", sq.Eq{"id": 1})
+			},
+			ErrNoTenant,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := sqlhelptest.InitMockDB(t)
+			tt.expectFn(mock)
+			err := tt.fn(db, tt.ctx)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package sqlhelp
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// In this file: context-bound transactions.  The service layer opens the
+// transaction with [InTx], and the repository code, that uses the [TxDB]
+// as the database, joins it without passing the transaction around:
+//
+//	db := sqlhelp.NewTxDB(sqlxDB)
+//	err := sqlhelp.InTx(ctx, db, func(ctx context.Context) error {
+//		if _, err := sqlhelp.Insert(ctx, db, "users", u); err != nil {
+//			return err
+//		}
+//		return audit.Log(ctx, db, "user created") // runs in the same tx
+//	})
+
+type txKey struct{}
+
+// ContextWithTx returns the context that carries the transaction.
+func ContextWithTx(ctx context.Context, tx *sqlx.Tx) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
+
+// TxFromContext returns the transaction from the context.
+func TxFromContext(ctx context.Context) (*sqlx.Tx, bool) {
+	tx, ok := ctx.Value(txKey{}).(*sqlx.Tx)
+	return tx, ok && tx != nil
+}
+
+// Beginner is the database that can begin the transaction, i.e. [sqlx.DB].
+type Beginner interface {
+	BeginTxx(ctx context.Context, opts *sql.TxOptions) (*sqlx.Tx, error)
+}
+
+// TxDB is the [sqlx.ExtContext] that runs the queries in the transaction
+// from the context, if there is one, or on the underlying database
+// otherwise.
+type TxDB struct {
+	db sqlx.ExtContext
+}
+
+var _ sqlx.ExtContext = (*TxDB)(nil)
+
+// NewTxDB returns the TxDB for the database db, i.e. [sqlx.DB] or
+// [Cluster].
+func NewTxDB(db sqlx.ExtContext) *TxDB {
+	return &TxDB{db: db}
+}
+
+// conn returns the transaction from the context or the database.
+func (t *TxDB) conn(ctx context.Context) sqlx.ExtContext {
+	if tx, ok := TxFromContext(ctx); ok {
+		return tx
+	}
+	return t.db
+}
+
+// BeginTxx begins the transaction on the underlying database.  It returns
+// an error if the database can't begin transactions.
+func (t *TxDB) BeginTxx(ctx context.Context, opts *sql.TxOptions) (*sqlx.Tx, error) {
+	b, ok := t.db.(Beginner)
+	if !ok {
+		return nil, errors.New("database does not support transactions")
+	}
+	return b.BeginTxx(ctx, opts)
+}
+
+// DriverName returns the driver name of the underlying database.
+func (t *TxDB) DriverName() string {
+	return t.db.DriverName()
+}
+
+// Rebind rebinds the query for the underlying database's bind type.
+func (t *TxDB) Rebind(query string) string {
+	return t.db.Rebind(query)
+}
+
+// BindNamed binds the named query for the underlying database's bind type.
+func (t *TxDB) BindNamed(query string, arg any) (string, []any, error) {
+	return t.db.BindNamed(query, arg)
+}
+
+// QueryContext runs the query in the transaction from the context, or on
+// the database.
+func (t *TxDB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return t.conn(ctx).QueryContext(ctx, query, args...)
+}
+
+// QueryxContext runs the query in the transaction from the context, or on
+// the database.
+func (t *TxDB) QueryxContext(ctx context.Context, query string, args ...any) (*sqlx.Rows, error) {
+	return t.conn(ctx).QueryxContext(ctx, query, args...)
+}
+
+// QueryRowxContext runs the query in the transaction from the context, or
+// on the database.
+func (t *TxDB) QueryRowxContext(ctx context.Context, query string, args ...any) *sqlx.Row {
+	return t.conn(ctx).QueryRowxContext(ctx, query, args...)
+}
+
+// ExecContext executes the statement in the transaction from the context,
+// or on the database.
+func (t *TxDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return t.conn(ctx).ExecContext(ctx, query, args...)
+}
+
+// InTx runs fn in the transaction.  The context passed to fn carries the
+// transaction, see [ContextWithTx].  The transaction is committed if fn
+// returns nil, and rolled back if it returns an error or panics.  If ctx
+// already carries the transaction, fn joins it, and the outermost InTx
+// commits or rolls it back.
+func InTx(ctx context.Context, db Beginner, fn func(ctx context.Context) error) (err error) {
+	if _, ok := TxFromContext(ctx); ok {
+		return fn(ctx)
+	}
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			if rerr := tx.Rollback(); rerr != nil {
+				err = errors.Join(err, rerr)
+			}
+			return
+		}
+		err = tx.Commit()
+	}()
+	return fn(ContextWithTx(ctx, tx))
+}
@@ -0,0 +1,111 @@
+package sqlhelp
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/rusq/sqlhelp/sqlhelptest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTxFromContext(t *testing.T) {
+	ctx := context.Background()
+	_, ok := TxFromContext(ctx)
+	assert.False(t, ok)
+	_, ok = TxFromContext(ContextWithTx(ctx, nil))
+	assert.False(t, ok)
+
+	db, mock := sqlhelptest.InitMockDB(t)
+	mock.ExpectBegin()
+	tx := db.MustBegin()
+	got, ok := TxFromContext(ContextWithTx(ctx, tx))
+	assert.True(t, ok)
+	assert.Same(t, tx, got)
+}
+
+func TestInTx(t *testing.T) {
+	ctx := context.Background()
+	errTest := errors.New("test")
+	tests := []struct {
+		name    string
+		fn      func(ctx context.Context, db *TxDB) error
+		expect  func(mock sqlmock.Sqlmock)
+		wantErr error
+	}{
+		{
+			name: "commit",
+			fn: func(ctx context.Context, db *TxDB) error {
+				if _, err := InsertPSQL(ctx, db, "users", "id", tenantUser{Name: "bob"}); err != nil {
+					return err
+				}
+				// nested InTx joins the transaction
+				return InTx(ctx, db, func(ctx context.Context) error {
+					return DeleteByID(ctx, db, "users", 2)
+				})
+			},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`INSERT INTO users \(name\) VALUES \(\$1\) ON CONFLICT DO NOTHING RETURNING id`).
+					WithArgs("bob").
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+				mock.ExpectExec(`DELETE FROM users WHERE id = \$1`).WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "rollback on error",
+			fn: func(ctx context.Context, db *TxDB) error {
+				if err := DeleteByID(ctx, db, "users", 2); err != nil {
+					return err
+				}
+				return errTest
+			},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(`DELETE FROM users WHERE id = \$1`).WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectRollback()
+			},
+			wantErr: errTest,
+		},
+		{
+			name: "begin error",
+			fn:   func(ctx context.Context, db *TxDB) error { return nil },
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin().WillReturnError(errTest)
+			},
+			wantErr: errTest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mdb, mock := sqlhelptest.InitMockDB(t)
+			tt.expect(mock)
+			db := NewTxDB(mdb)
+			err := InTx(ctx, db, func(ctx context.Context) error { return tt.fn(ctx, db) })
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestInTx_panic(t *testing.T) {
+	mdb, mock := sqlhelptest.InitMockDB(t)
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	assert.PanicsWithValue(t, "boom", func() {
+		InTx(context.Background(), mdb, func(ctx context.Context) error { panic("boom") })
+	})
+}
+
+func TestTxDB_noTx(t *testing.T) {
+	mdb, mock := sqlhelptest.InitMockDB(t)
+	db := NewTxDB(mdb)
+	mock.ExpectQuery(`SELECT 1 as X FROM users WHERE id = \$1`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"x"}).AddRow(1))
+	ok, err := Exists(context.Background(), db, "users", sq.Eq{"id": 1})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
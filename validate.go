@@ -0,0 +1,227 @@
+package sqlhelp
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// In this file: struct validation.  Fields are validated using the
+// [ValidateTag] struct tag, the tag value is the comma separated list of
+// rules, i.e.:
+//
+//	Name  string `db:"name" validate:"required,maxlen=64"`
+//	Age   int    `db:"age" validate:"min=18"`
+//	Role  string `db:"role" validate:"oneof=admin user guest"`
+//
+// Supported rules:
+//
+//   - required: the value must not be empty (see omitempty), nor a
+//     [driver.Valuer] that returns NULL, i.e. invalid sql.NullString;
+//   - maxlen=N: the length of the string (in runes), slice or map must not
+//     exceed N;
+//   - min=N: the number must be greater than or equal to N, for strings,
+//     slices and maps, the length must be at least N;
+//   - oneof=A B C: the value must be one of the space separated values.
+//
+// Nil pointers satisfy all rules except required.
+
+// ValidateTag is the struct tag name with the validation rules.
+var ValidateTag = "validate"
+
+// Validate is called by [Insert], [Update] and their variants before the
+// statement is built.  It defaults to [ValidateStruct], and may be replaced
+// with an external validator, or set to nil to disable validation.
+var Validate func(v any) error = ValidateStruct
+
+// FieldError describes the field that failed validation.
+type FieldError struct {
+	Field   string // Go field name
+	Column  string // column name
+	Rule    string // failed rule, i.e. "maxlen=64"
+	Message string // human readable message
+}
+
+func (e FieldError) Error() string {
+	return e.Column + ": " + e.Message
+}
+
+// ValidationError is returned when one or more fields fail validation.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Error()
+	}
+	return "validation failed: " + strings.Join(msgs, "; ")
+}
+
+// ValidateStruct validates the struct, or a pointer to the struct, v using
+// the rules in the [ValidateTag] struct tags.  It returns *[ValidationError]
+// listing all failing fields, or an error if the rule is malformed.
+func ValidateStruct(v any) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+	var verr ValidationError
+	for _, f := range fieldsOf(rv.Type()) {
+		tag := rv.Type().FieldByIndex(f.index).Tag.Get(ValidateTag)
+		if tag == "" || tag == "-" {
+			continue
+		}
+		fv := rv.FieldByIndex(f.index)
+		for _, rule := range strings.Split(tag, ",") {
+			msg, err := checkRule(fv, rule)
+			if err != nil {
+				return fmt.Errorf("field %s: %w", f.name, err)
+			}
+			if msg != "" {
+				verr.Fields = append(verr.Fields, FieldError{Field: f.name, Column: f.column, Rule: rule, Message: msg})
+			}
+		}
+	}
+	if len(verr.Fields) > 0 {
+		return &verr
+	}
+	return nil
+}
+
+// checkRule checks the value v against the rule.  It returns the message, if
+// the value does not satisfy the rule, or an error if the rule is invalid.
+func checkRule(v reflect.Value, rule string) (string, error) {
+	name, arg, _ := strings.Cut(rule, "=")
+	if name == "required" {
+		if isEmpty(v) || isNullValuer(v) {
+			return "is required", nil
+		}
+		return "", nil
+	}
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return "", nil
+		}
+		v = v.Elem()
+	}
+	switch name {
+	case "maxlen":
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			return "", fmt.Errorf("invalid rule %q: %w", rule, err)
+		}
+		l, ok := length(v)
+		if !ok {
+			return "", fmt.Errorf("invalid rule %q for %s", rule, v.Type())
+		}
+		if l > n {
+			return fmt.Sprintf("length must not exceed %d", n), nil
+		}
+	case "min":
+		n, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return "", fmt.Errorf("invalid rule %q: %w", rule, err)
+		}
+		if l, ok := length(v); ok {
+			if float64(l) < n {
+				return fmt.Sprintf("length must be at least %s", arg), nil
+			}
+			return "", nil
+		}
+		f, ok := number(v)
+		if !ok {
+			return "", fmt.Errorf("invalid rule %q for %s", rule, v.Type())
+		}
+		if f < n {
+			return fmt.Sprintf("must be at least %s", arg), nil
+		}
+	case "oneof":
+		if !slices.Contains(strings.Fields(arg), fmt.Sprint(v.Interface())) {
+			return fmt.Sprintf("must be one of: %s", arg), nil
+		}
+	default:
+		return "", fmt.Errorf("unknown rule %q", rule)
+	}
+	return "", nil
+}
+
+// isNullValuer returns true if v implements [driver.Valuer], and its value
+// is NULL.
+func isNullValuer(v reflect.Value) bool {
+	if !v.CanInterface() {
+		return false
+	}
+	vr, ok := v.Interface().(driver.Valuer)
+	if !ok && v.CanAddr() {
+		vr, ok = v.Addr().Interface().(driver.Valuer)
+	}
+	if !ok {
+		return false
+	}
+	val, err := vr.Value()
+	return err == nil && val == nil
+}
+
+// length returns the length of the string (in runes), slice, array or map.
+func length(v reflect.Value) (int, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return utf8.RuneCountInString(v.String()), true
+	case reflect.Array, reflect.Map, reflect.Slice:
+		return v.Len(), true
+	}
+	return 0, false
+}
+
+// number returns the numeric value of v.
+func number(v reflect.Value) (float64, bool) {
+	switch {
+	case v.CanInt():
+		return float64(v.Int()), true
+	case v.CanUint():
+		return float64(v.Uint()), true
+	case v.CanFloat():
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+// validate calls the [Validate] function, if set.
+func validate(a any) error {
+	if Validate == nil {
+		return nil
+	}
+	return Validate(a)
+}
+
+// validatePartial calls the [Validate] function for the partial update, and
+// drops the errors of the fields that are omitted from the UPDATE statement.
+func validatePartial(a any) error {
+	err := validate(a)
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		return err
+	}
+	written := toMap(DialectUnknown, a, true)
+	fields := slices.DeleteFunc(slices.Clone(verr.Fields), func(f FieldError) bool {
+		_, ok := written[f.Column]
+		return !ok
+	})
+	if len(fields) == 0 {
+		return nil
+	}
+	return &ValidationError{Fields: fields}
+}
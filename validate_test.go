@@ -0,0 +1,162 @@
+package sqlhelp
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/rusq/sqlhelp/sqlhelptest"
+	"github.com/stretchr/testify/assert"
+)
+
+type validatedStruct struct {
+	ID    int64    `db:"id,omitempty"`
+	Name  string   `db:"name" validate:"required,maxlen=5"`
+	Age   int      `db:"age" validate:"min=18"`
+	Role  string   `db:"role" validate:"oneof=admin user"`
+	Tags  []string `db:"tags" validate:"min=1"`
+	Score *float64 `db:"score" validate:"min=0.5"`
+}
+
+func TestValidateStruct(t *testing.T) {
+	ok := validatedStruct{Name: "bob", Age: 18, Role: "user", Tags: []string{"a"}}
+	low := 0.1
+	tests := []struct {
+		name    string
+		v       any
+		want    []FieldError
+		wantErr bool
+	}{
+		{"valid", ok, nil, false},
+		{"valid pointer", &ok, nil, false},
+		{"nil pointer", (*validatedStruct)(nil), nil, false},
+		{"not a struct", 42, nil, false},
+		{
+			"all failing",
+			validatedStruct{Name: "", Age: 17, Role: "root", Score: &low},
+			[]FieldError{
+				{Field: "Name", Column: "name", Rule: "required", Message: "is required"},
+				{Field: "Age", Column: "age", Rule: "min=18", Message: "must be at least 18"},
+				{Field: "Role", Column: "role", Rule: "oneof=admin user", Message: "must be one of: admin user"},
+				{Field: "Tags", Column: "tags", Rule: "min=1", Message: "length must be at least 1"},
+				{Field: "Score", Column: "score", Rule: "min=0.5", Message: "must be at least 0.5"},
+			},
+			false,
+		},
+		{
+			"maxlen counts runes",
+			validatedStruct{Name: "привет", Age: 20, Role: "admin", Tags: []string{"a"}},
+			[]FieldError{{Field: "Name", Column: "name", Rule: "maxlen=5", Message: "length must not exceed 5"}},
+			false,
+		},
+		{
+			"required null valuer",
+			struct {
+				Note sql.NullString `db:"note" validate:"required"`
+				Ptr  *sql.NullInt64 `db:"ptr" validate:"required"`
+				Set  sql.NullString `db:"set" validate:"required"`
+			}{Ptr: &sql.NullInt64{}, Set: sql.NullString{String: "", Valid: true}},
+			[]FieldError{
+				{Field: "Note", Column: "note", Rule: "required", Message: "is required"},
+				{Field: "Ptr", Column: "ptr", Rule: "required", Message: "is required"},
+			},
+			false,
+		},
+		{
+			"invalid rule",
+			struct {
+				N int `db:"n" validate:"maxlen=x"`
+			}{},
+			nil,
+			true,
+		},
+		{
+			"unknown rule",
+			struct {
+				N int `db:"n" validate:"email"`
+			}{},
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateStruct(tt.v)
+			var verr *ValidationError
+			switch {
+			case tt.wantErr:
+				assert.Error(t, err)
+				assert.False(t, errors.As(err, &verr))
+			case tt.want == nil:
+				assert.NoError(t, err)
+			default:
+				if assert.ErrorAs(t, err, &verr) {
+					assert.Equal(t, tt.want, verr.Fields)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateOnWrite(t *testing.T) {
+	ctx := context.Background()
+	bad := validatedStruct{Name: "toolong", Age: 20, Role: "user", Tags: []string{"a"}}
+	t.Run("insert", func(t *testing.T) {
+		db, mock := sqlhelptest.InitMockDB(t)
+		_, err := Insert(ctx, db, "test_table", bad)
+		var verr *ValidationError
+		assert.ErrorAs(t, err, &verr)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("update", func(t *testing.T) {
+		db, mock := sqlhelptest.InitMockDB(t)
+		_, err := Update(ctx, db, "test_table", &bad, sq.Eq{"id": 1})
+		var verr *ValidationError
+		assert.ErrorAs(t, err, &verr)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("partial update", func(t *testing.T) {
+		type partial struct {
+			ID   int64  `db:"id,omitempty"`
+			Name string `db:"name,omitempty" validate:"required"`
+			Role string `db:"role,omitempty" validate:"oneof=admin user"`
+		}
+		db, mock := sqlhelptest.InitMockDB(t)
+		mock.ExpectExec(`UPDATE test_table SET role = \$1 WHERE id = \$2`).
+			WithArgs("admin", 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		_, err := Update(ctx, db, "test_table", &partial{Role: "admin"}, sq.Eq{"id": 1})
+		assert.NoError(t, err, "omitted required field is not checked")
+
+		_, err = Update(ctx, db, "test_table", &partial{Role: "root"}, sq.Eq{"id": 1})
+		var verr *ValidationError
+		if assert.ErrorAs(t, err, &verr) {
+			assert.Equal(t, []FieldError{{Field: "Role", Column: "role", Rule: "oneof=admin user", Message: "must be one of: admin user"}}, verr.Fields)
+		}
+	})
+	t.Run("external validator", func(t *testing.T) {
+		errExternal := errors.New("external")
+		old := Validate
+		t.Cleanup(func() { Validate = old })
+		Validate = func(any) error { return errExternal }
+		db, _ := sqlhelptest.InitMockDB(t)
+		_, err := Insert(ctx, db, "test_table", validatedStruct{})
+		assert.ErrorIs(t, err, errExternal)
+	})
+	t.Run("disabled", func(t *testing.T) {
+		old := Validate
+		t.Cleanup(func() { Validate = old })
+		Validate = nil
+		db, mock := sqlhelptest.InitMockDB(t)
+		mock.ExpectExec(`INSERT INTO test_table \(name\) VALUES \(\$1\)`).
+			WithArgs("toolong").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		_, err := Insert(ctx, db, "test_table", struct {
+			Name string `db:"name" validate:"maxlen=5"`
+		}{Name: "toolong"})
+		assert.NoError(t, err)
+	})
+}
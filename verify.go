@@ -0,0 +1,236 @@
+package sqlhelp
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ColumnMismatch describes a column that has a type in the database that is
+// incompatible with the type of the struct field.
+type ColumnMismatch struct {
+	Column string // column name
+	Field  string // struct field name
+	GoType string // struct field type
+	DBType string // column type reported by the database
+}
+
+func (m ColumnMismatch) String() string {
+	return fmt.Sprintf("%s (field %s %s, column %s)", m.Column, m.Field, m.GoType, m.DBType)
+}
+
+// SchemaError is returned by [VerifySchema] if the table does not match the
+// struct definition.
+type SchemaError struct {
+	Table      string
+	Missing    []string         // struct columns missing from the table
+	Extra      []string         // table columns not mapped to the struct
+	Mismatched []ColumnMismatch // columns with incompatible types
+}
+
+func (e *SchemaError) Error() string {
+	var parts []string
+	if len(e.Missing) > 0 {
+		parts = append(parts, "missing columns: "+strings.Join(e.Missing, ", "))
+	}
+	if len(e.Extra) > 0 {
+		parts = append(parts, "extra columns: "+strings.Join(e.Extra, ", "))
+	}
+	if len(e.Mismatched) > 0 {
+		mm := make([]string, len(e.Mismatched))
+		for i, m := range e.Mismatched {
+			mm[i] = m.String()
+		}
+		parts = append(parts, "type mismatch: "+strings.Join(mm, ", "))
+	}
+	return "schema mismatch for table " + e.Table + ": " + strings.Join(parts, "; ")
+}
+
+// VerifySchema checks that the columns of the table match the tagged fields
+// of the struct type T.  It returns a [*SchemaError] listing missing, extra
+// and type-mismatched columns, or nil if the table matches.  Supported
+// dialects are Postgres (information_schema) and SQLite (PRAGMA table_info).
+func VerifySchema[T any](ctx context.Context, db sqlx.ExtContext, table string) error {
+	table, err := tableOf[T](table)
+	if err != nil {
+		return err
+	}
+	d := DialectOf(db)
+	name, err := tableName(ctx, db, table)
+	if err != nil {
+		return err
+	}
+	live, err := tableColumns(ctx, db, d, name)
+	if err != nil {
+		return err
+	}
+	if len(live) == 0 {
+		return fmt.Errorf("%s: table not found", table)
+	}
+
+	serr := &SchemaError{Table: table}
+	seen := make(map[string]bool)
+	for _, f := range fieldsOf(reflect.TypeFor[T]()) {
+		if seen[f.column] {
+			continue
+		}
+		seen[f.column] = true
+		dbType, ok := live[f.column]
+		if !ok {
+			serr.Missing = append(serr.Missing, f.column)
+			continue
+		}
+		if _, hasType := f.opts.value("type"); hasType {
+			// custom types can't be reliably compared.
+			continue
+		}
+		k, _ := f.kind()
+		if !d.compatible(k, dbType) {
+			serr.Mismatched = append(serr.Mismatched, ColumnMismatch{
+				Column: f.column,
+				Field:  f.name,
+				GoType: f.typ.String(),
+				DBType: dbType,
+			})
+		}
+	}
+	for col := range live {
+		if !seen[col] {
+			serr.Extra = append(serr.Extra, col)
+		}
+	}
+	slices.Sort(serr.Extra)
+
+	if len(serr.Missing)+len(serr.Extra)+len(serr.Mismatched) == 0 {
+		return nil
+	}
+	return serr
+}
+
+// tableColumns returns the map of column name to column type for the table,
+// that may be quoted and qualified with the schema.
+func tableColumns(ctx context.Context, db sqlx.ExtContext, d Dialect, table string) (map[string]string, error) {
+	parts, err := storedIdent(d, table)
+	if err != nil {
+		return nil, err
+	}
+	name := parts[len(parts)-1]
+	var (
+		query string
+		args  []any
+	)
+	switch d {
+	case DialectPostgres:
+		if len(parts) > 1 {
+			query = "SELECT column_name, data_type FROM information_schema.columns WHERE table_schema = ? AND table_name = ?"
+			args = []any{parts[len(parts)-2], name}
+		} else {
+			query = "SELECT column_name, data_type FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = ?"
+			args = []any{name}
+		}
+	case DialectSQLite:
+		if len(parts) > 1 {
+			query = "SELECT name, type FROM pragma_table_info(?, ?)"
+			args = []any{name, parts[len(parts)-2]}
+		} else {
+			query = "SELECT name, type FROM pragma_table_info(?)"
+			args = []any{name}
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedDialect, d)
+	}
+	rows, err := db.QueryxContext(ctx, db.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	cols := make(map[string]string)
+	for rows.Next() {
+		var name, typ string
+		if err := rows.Scan(&name, &typ); err != nil {
+			return nil, err
+		}
+		cols[name] = typ
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return cols, nil
+}
+
+// compatible returns true if the database type dbType can hold the values of
+// the column kind k.  Unknown types are considered compatible.
+func (d Dialect) compatible(k colKind, dbType string) bool {
+	want, got := k.family(), d.kindOfType(dbType).family()
+	if want == kindInvalid || got == kindInvalid {
+		return true
+	}
+	if d == DialectSQLite && (want == kindBool && got == kindBigInt) {
+		// sqlite stores booleans as integers.
+		return true
+	}
+	if d == DialectSQLite && ((want == kindJSON || want == kindArray) && got == kindText) {
+		return true
+	}
+	return want == got
+}
+
+// family returns the broadest kind of the same family, i.e. all integers
+// become kindBigInt.
+func (k colKind) family() colKind {
+	switch k {
+	case kindSmallInt, kindInt, kindBigInt:
+		return kindBigInt
+	case kindReal, kindDouble:
+		return kindDouble
+	default:
+		return k
+	}
+}
+
+// kindOfType returns the column kind for the database type name.
+func (d Dialect) kindOfType(dbType string) colKind {
+	t := strings.ToUpper(dbType)
+	switch d {
+	case DialectPostgres:
+		switch {
+		case t == "BOOLEAN":
+			return kindBool
+		case t == "SMALLINT" || t == "INTEGER" || t == "BIGINT":
+			return kindBigInt
+		case t == "REAL" || t == "DOUBLE PRECISION":
+			return kindDouble
+		case t == "TEXT" || strings.HasPrefix(t, "CHARACTER"):
+			return kindText
+		case t == "BYTEA":
+			return kindBytes
+		case t == "JSON" || t == "JSONB":
+			return kindJSON
+		case t == "ARRAY" || strings.HasSuffix(t, "[]"):
+			return kindArray
+		case strings.HasPrefix(t, "TIMESTAMP") || t == "DATE":
+			return kindTime
+		}
+	case DialectSQLite:
+		// see https://www.sqlite.org/datatype3.html#determination_of_column_affinity
+		switch {
+		case strings.Contains(t, "INT"):
+			return kindBigInt
+		case strings.Contains(t, "CHAR") || strings.Contains(t, "CLOB") || strings.Contains(t, "TEXT"):
+			return kindText
+		case strings.Contains(t, "BLOB"):
+			return kindBytes
+		case strings.Contains(t, "REAL") || strings.Contains(t, "FLOA") || strings.Contains(t, "DOUB"):
+			return kindDouble
+		case strings.Contains(t, "BOOL"):
+			return kindBool
+		case strings.Contains(t, "DATE") || strings.Contains(t, "TIME"):
+			return kindTime
+		}
+	}
+	return kindInvalid
+}
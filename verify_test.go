@@ -0,0 +1,138 @@
+package sqlhelp
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/rusq/sqlhelp/sqlhelptest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifySchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		stmt    string
+		want    *SchemaError
+		wantErr bool
+	}{
+		{
+			"match",
+			"CREATE TABLE test_table (id INTEGER PRIMARY KEY, name TEXT, score REAL, active BOOLEAN, data BLOB, note VARCHAR(20), parent_id INT, created_at DATETIME)",
+			nil,
+			false,
+		},
+		{
+			"drift",
+			"CREATE TABLE test_table (id INTEGER PRIMARY KEY, full_name TEXT, score TEXT, active INTEGER, data BLOB, note TEXT, parent_id INTEGER, created_at DATETIME)",
+			&SchemaError{
+				Table:   "test_table",
+				Missing: []string{"name"},
+				Extra:   []string{"full_name"},
+				Mismatched: []ColumnMismatch{
+					{Column: "score", Field: "Score", GoType: "float64", DBType: "TEXT"},
+				},
+			},
+			true,
+		},
+		{
+			"no table",
+			"CREATE TABLE other_table (id INTEGER PRIMARY KEY)",
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			db := sqlhelptest.InitSqliteDB(t)
+			if _, err := db.ExecContext(ctx, tt.stmt); err != nil {
+				t.Fatal(err)
+			}
+			err := VerifySchema[schemaStruct](ctx, db, "test_table")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("VerifySchema() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.want != nil {
+				assert.Equal(t, tt.want, err)
+			}
+		})
+	}
+}
+
+func TestVerifySchema_postgres(t *testing.T) {
+	db, mock := sqlhelptest.InitMockDB(t)
+	mock.ExpectQuery(`SELECT column_name, data_type FROM information_schema.columns WHERE table_schema = \$1 AND table_name = \$2`).
+		WithArgs("public", "test_table").
+		WillReturnRows(sqlmock.NewRows([]string{"column_name", "data_type"}).
+			AddRow("tenant_id", "bigint").
+			AddRow("key", "character varying").
+			AddRow("value", "text"))
+
+	err := VerifySchema[compositeStruct](context.Background(), db, "public.test_table")
+	assert.NoError(t, err)
+}
+
+func TestVerifySchema_names(t *testing.T) {
+	const columns = "(id INTEGER PRIMARY KEY, name TEXT, score REAL, active BOOLEAN, data BLOB, note VARCHAR(20), parent_id INT, created_at DATETIME)"
+	ctx := context.Background()
+	db := sqlhelptest.InitSqliteDB(t)
+	for _, stmt := range []string{
+		`CREATE TABLE test_table ` + columns,
+		`CREATE TABLE "My Table" ` + columns,
+	} {
+		db.MustExecContext(ctx, stmt)
+	}
+	tests := []struct {
+		name  string
+		ctx   context.Context
+		table string
+	}{
+		{"quoted", ctx, `"My Table"`},
+		{"quoted qualified", ctx, `"main"."test_table"`},
+		{"schema from context", WithSchema(ctx, "main"), "test_table"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.NoError(t, VerifySchema[schemaStruct](tt.ctx, db, tt.table))
+		})
+	}
+	t.Run("tabler", func(t *testing.T) {
+		assert.NoError(t, VerifySchema[tabledSchema](ctx, db, ""))
+	})
+	t.Run("wrong schema", func(t *testing.T) {
+		assert.Error(t, VerifySchema[schemaStruct](WithSchema(ctx, "nope"), db, "test_table"))
+	})
+}
+
+func TestVerifySchema_postgresNames(t *testing.T) {
+	const (
+		qSchema  = `SELECT column_name, data_type FROM information_schema.columns WHERE table_schema = \$1 AND table_name = \$2`
+		qCurrent = `SELECT column_name, data_type FROM information_schema.columns WHERE table_schema = current_schema\(\) AND table_name = \$1`
+	)
+	tests := []struct {
+		name  string
+		ctx   context.Context
+		table string
+		query string
+		args  []driver.Value
+	}{
+		{"folded", context.Background(), "Test_Table", qCurrent, []driver.Value{"test_table"}},
+		{"quoted", context.Background(), `"S"."Test_Table"`, qSchema, []driver.Value{"S", "Test_Table"}},
+		{"schema from context", WithSchema(context.Background(), "Tenant"), "test_table", qSchema, []driver.Value{"tenant", "test_table"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := sqlhelptest.InitMockDB(t)
+			mock.ExpectQuery(tt.query).
+				WithArgs(tt.args...).
+				WillReturnRows(sqlmock.NewRows([]string{"column_name", "data_type"}).
+					AddRow("tenant_id", "bigint").
+					AddRow("key", "character varying").
+					AddRow("value", "text"))
+			assert.NoError(t, VerifySchema[compositeStruct](tt.ctx, db, tt.table))
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package sqlhelp
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// ErrUnknownColumn is returned when the column is not mapped to any field of
+// the struct.
+var ErrUnknownColumn = errors.New("unknown column")
+
+// Filter is the where clause builder for the struct type T.  Columns are
+// referenced by pointers to the fields of the Model, or by names, that are
+// checked against the tags of T, so that filters don't drift from the struct
+// definition.  Filter implements [sq.Sqlizer], and the first invalid column
+// reference is reported by ToSql.  Conditions are joined with AND.  Column
+// names are quoted where necessary for the dialect, see [Filter.Dialect].
+//
+//	w := sqlhelp.Where[User]()
+//	u := w.Model()
+//	w.Field(&u.Name).Eq("x").Field(&u.Age).Gt(18)
+//	rows, err := sqlhelp.Select[User](ctx, db, "users", w)
+type Filter[T any] struct {
+	model *T
+	d     Dialect
+	conds sq.And
+	err   error
+}
+
+// Where returns a new [Filter] for the struct type T.
+func Where[T any]() *Filter[T] {
+	return &Filter[T]{model: new(T)}
+}
+
+// Dialect sets the dialect, that the column names are quoted for.  The
+// default quotes with double quotes, as Postgres and SQLite do, MySQL
+// requires backticks.
+func (f *Filter[T]) Dialect(d Dialect) *Filter[T] {
+	f.d = d
+	return f
+}
+
+// Model returns the model, pointers to the fields of which are accepted by
+// [Filter.Field].
+func (f *Filter[T]) Model() *T {
+	return f.model
+}
+
+// Field returns the column mapped to the field, ptr must be a pointer to the
+// field of the [Filter.Model].
+func (f *Filter[T]) Field(ptr any) Column[T] {
+	col, err := f.column(ptr)
+	if err != nil && f.err == nil {
+		f.err = err
+	}
+	return Column[T]{f: f, name: col}
+}
+
+// column returns the column name of the field of the model, pointed to by
+// ptr.
+func (f *Filter[T]) column(ptr any) (string, error) {
+	pv := reflect.ValueOf(ptr)
+	if pv.Kind() != reflect.Pointer || pv.IsNil() {
+		return "", fmt.Errorf("field reference must be a non-nil pointer, got %T", ptr)
+	}
+	base := reflect.ValueOf(f.model).Pointer()
+	addr := pv.Pointer()
+	if addr >= base {
+		off := addr - base
+		for _, fld := range fieldsOf(reflect.TypeFor[T]()) {
+			if fld.offset == off && fld.typ == pv.Type().Elem() {
+				return fld.column, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("%w: %T does not point to a mapped field of %s", ErrUnknownColumn, ptr, reflect.TypeFor[T]())
+}
+
+// Col returns the column by its name, the name must be one of the columns of
+// the struct type T.
+func (f *Filter[T]) Col(name string) Column[T] {
+	if err := CheckColumn[T](name); err != nil && f.err == nil {
+		f.err = err
+	}
+	return Column[T]{f: f, name: name}
+}
+
+// And adds arbitrary conditions to the filter.
+func (f *Filter[T]) And(conds ...sq.Sqlizer) *Filter[T] {
+	f.conds = append(f.conds, conds...)
+	return f
+}
+
+// ToSql implements [sq.Sqlizer].
+func (f *Filter[T]) ToSql() (string, []any, error) {
+	if f.err != nil {
+		return "", nil, f.err
+	}
+	return f.conds.ToSql()
+}
+
+// Column is the column of the struct type T, that is used to add conditions
+// to the [Filter].
+type Column[T any] struct {
+	f    *Filter[T]
+	name string
+}
+
+// Name returns the column name.
+func (c Column[T]) Name() string {
+	return c.name
+}
+
+// cond adds the condition, built by fn for the quoted column name, to the
+// filter.
+func (c Column[T]) cond(fn func(col string) sq.Sqlizer) *Filter[T] {
+	col, err := c.f.d.Identifier(c.name)
+	if err != nil {
+		if c.f.err == nil {
+			c.f.err = err
+		}
+		return c.f
+	}
+	return c.f.And(fn(col))
+}
+
+// Eq adds the "column = v" condition, or "column IN (...)", if v is a slice.
+func (c Column[T]) Eq(v any) *Filter[T] {
+	return c.cond(func(col string) sq.Sqlizer { return sq.Eq{col: v} })
+}
+
+// NotEq adds the "column <> v" condition, or "column NOT IN (...)", if v is a
+// slice.
+func (c Column[T]) NotEq(v any) *Filter[T] {
+	return c.cond(func(col string) sq.Sqlizer { return sq.NotEq{col: v} })
+}
+
+// Lt adds the "column < v" condition.
+func (c Column[T]) Lt(v any) *Filter[T] {
+	return c.cond(func(col string) sq.Sqlizer { return sq.Lt{col: v} })
+}
+
+// LtOrEq adds the "column <= v" condition.
+func (c Column[T]) LtOrEq(v any) *Filter[T] {
+	return c.cond(func(col string) sq.Sqlizer { return sq.LtOrEq{col: v} })
+}
+
+// Gt adds the "column > v" condition.
+func (c Column[T]) Gt(v any) *Filter[T] {
+	return c.cond(func(col string) sq.Sqlizer { return sq.Gt{col: v} })
+}
+
+// GtOrEq adds the "column >= v" condition.
+func (c Column[T]) GtOrEq(v any) *Filter[T] {
+	return c.cond(func(col string) sq.Sqlizer { return sq.GtOrEq{col: v} })
+}
+
+// Like adds the "column LIKE pattern" condition.
+func (c Column[T]) Like(pattern string) *Filter[T] {
+	return c.cond(func(col string) sq.Sqlizer { return sq.Like{col: pattern} })
+}
+
+// IsNull adds the "column IS NULL" condition.
+func (c Column[T]) IsNull() *Filter[T] {
+	return c.cond(func(col string) sq.Sqlizer { return sq.Eq{col: nil} })
+}
+
+// IsNotNull adds the "column IS NOT NULL" condition.
+func (c Column[T]) IsNotNull() *Filter[T] {
+	return c.cond(func(col string) sq.Sqlizer { return sq.NotEq{col: nil} })
+}
+
+// CheckColumn returns [ErrUnknownColumn] if the column is not mapped to any
+// field of the struct type T.  The column may be quoted and qualified, i.e.
+// `u."user"`.
+func CheckColumn[T any](column string) error {
+	name := column
+	if parts, err := splitAnyIdent(column); err == nil {
+		name, _ = unquotePart(parts[len(parts)-1])
+	}
+	for _, f := range fieldsOf(reflect.TypeFor[T]()) {
+		if f.column == name {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s.%s", ErrUnknownColumn, reflect.TypeFor[T](), column)
+}
+
+// CheckWhere verifies that all columns used in the squirrel where clause,
+// i.e. [sq.Eq], [sq.Lt], combined with [sq.And] or [sq.Or], are mapped to
+// the fields of the struct type T.  Raw expressions are not checked.  It is
+// intended to be used in tests to catch column names that drifted from the
+// struct tags.
+func CheckWhere[T any](where sq.Sqlizer) error {
+	var errs []error
+	check := func(m map[string]any) {
+		for col := range m {
+			if err := CheckColumn[T](col); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	switch w := where.(type) {
+	case sq.Eq:
+		check(w)
+	case sq.NotEq:
+		check(w)
+	case sq.Lt:
+		check(w)
+	case sq.LtOrEq:
+		check(w)
+	case sq.Gt:
+		check(w)
+	case sq.GtOrEq:
+		check(w)
+	case sq.Like:
+		check(w)
+	case sq.NotLike:
+		check(w)
+	case sq.ILike:
+		check(w)
+	case sq.NotILike:
+		check(w)
+	case sq.And:
+		for _, s := range w {
+			errs = append(errs, CheckWhere[T](s))
+		}
+	case sq.Or:
+		for _, s := range w {
+			errs = append(errs, CheckWhere[T](s))
+		}
+	case *Filter[T]:
+		errs = append(errs, w.err)
+		for _, s := range w.conds {
+			errs = append(errs, CheckWhere[T](s))
+		}
+	}
+	return errors.Join(errs...)
+}
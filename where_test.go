@@ -0,0 +1,157 @@
+package sqlhelp
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/rusq/sqlhelp/sqlhelptest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilter(t *testing.T) {
+	var other TestStruct
+	tests := []struct {
+		name     string
+		build    func(w *Filter[TestStruct], m *TestStruct)
+		wantSQL  string
+		wantArgs []any
+		wantErr  error
+	}{
+		{
+			"fields",
+			func(w *Filter[TestStruct], m *TestStruct) {
+				w.Field(&m.Name).Eq("test").Field(&m.CreatedAt).Gt(testDate).Field(&m.Int).Eq([]int{1, 2})
+			},
+			"(name = ? AND created_at > ? AND int_t IN (?,?))",
+			[]any{"test", testDate, 1, 2},
+			nil,
+		},
+		{
+			"nested field",
+			func(w *Filter[TestStruct], m *TestStruct) {
+				w.Field(&m.Nested.Street).Like("main%").Field(&m.NestedInt).IsNull()
+			},
+			"(street LIKE ? AND nested_int IS NULL)",
+			[]any{"main%"},
+			nil,
+		},
+		{
+			"column names",
+			func(w *Filter[TestStruct], m *TestStruct) {
+				w.Col("id").NotEq(1).Col("bool_t").IsNotNull()
+			},
+			"(id <> ? AND bool_t IS NOT NULL)",
+			[]any{1},
+			nil,
+		},
+		{
+			"unknown column",
+			func(w *Filter[TestStruct], m *TestStruct) {
+				w.Col("identifier").Eq(1)
+			},
+			"",
+			nil,
+			ErrUnknownColumn,
+		},
+		{
+			"foreign field",
+			func(w *Filter[TestStruct], m *TestStruct) {
+				w.Field(&other.Name).Eq("test")
+			},
+			"",
+			nil,
+			ErrUnknownColumn,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := Where[TestStruct]()
+			tt.build(w, w.Model())
+			gotSQL, gotArgs, err := w.ToSql()
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.wantSQL, gotSQL)
+			assert.Equal(t, tt.wantArgs, gotArgs)
+		})
+	}
+}
+
+func TestFilter_quoting(t *testing.T) {
+	type account struct {
+		User  string `db:"user"`
+		Order int    `db:"order"`
+	}
+	w := Where[account]()
+	w.Field(&w.Model().User).Eq("bob").Col("order").Gt(1)
+	gotSQL, gotArgs, err := w.ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, `("user" = ? AND "order" > ?)`, gotSQL)
+	assert.Equal(t, []any{"bob", 1}, gotArgs)
+
+	w = Where[account]().Dialect(DialectMySQL)
+	w.Col("user").IsNull()
+	gotSQL, _, err = w.ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "(`user` IS NULL)", gotSQL)
+}
+
+func TestFilter_select(t *testing.T) {
+	db, mock := sqlhelptest.InitMockDB(t)
+	mock.ExpectQuery(`SELECT .* FROM test_table WHERE \(id = \$1\)`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(testStructCols).AddRow(testStructBinds...))
+	w := Where[TestStruct]()
+	w.Field(&w.Model().ID).Eq(1)
+	got, err := SelectRow[TestStruct](context.Background(), db, "test_table", w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, &filledStruct, got)
+}
+
+func TestCheckWhere(t *testing.T) {
+	tests := []struct {
+		name    string
+		where   sq.Sqlizer
+		wantErr bool
+	}{
+		{"eq", sq.Eq{"id": 1, "name": "x"}, false},
+		{"nested", sq.And{sq.Eq{"id": 1}, sq.Or{sq.Lt{"int_t": 2}, sq.Like{"street": "x"}}}, false},
+		{"unknown", sq.Eq{"identifier": 1}, true},
+		{"nested unknown", sq.Or{sq.Eq{"id": 1}, sq.Gt{"nestedint": 2}}, true},
+		{"raw expression", sq.Expr("whatever = ?", 1), false},
+		{"filter", Where[TestStruct]().Col("nope").Eq(1), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := CheckWhere[TestStruct](tt.where); (err != nil) != tt.wantErr {
+				t.Errorf("CheckWhere() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckWhere_reservedWord(t *testing.T) {
+	type account struct {
+		ID   int    `db:"id"`
+		User string `db:"user"`
+	}
+	tests := []struct {
+		name    string
+		where   sq.Sqlizer
+		wantErr bool
+	}{
+		{"filter", Where[account]().Col("user").Eq("bob"), false},
+		{"quoted key", sq.Eq{`"user"`: "bob"}, false},
+		{"backticks", sq.Eq{"`user`": "bob"}, false},
+		{"qualified", sq.Eq{`a."user"`: "bob"}, false},
+		{"quoted unknown", sq.Eq{`"User"`: "bob"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckWhere[account](tt.where)
+			assert.Equal(t, tt.wantErr, err != nil, err)
+		})
+	}
+}